@@ -3,15 +3,128 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"dush/internal/app"
 	"dush/internal/repl"
 )
 
+const usage = "usage: dush [--login] [--norc] [--noprofile] [-elnx] [-o option] [-c command [name [arg ...]] | script [arg ...]]"
+
+// invocation holds what the command line asks dush to run.
+type invocation struct {
+	command    string   // Commands given with -c
+	hasCommand bool     // Whether -c was given
+	script     string   // Script file to run, or "" for the REPL
+	args       []string // Positional parameters, after $0 for -c
+	login      bool     // Whether to source the login profiles
+	noRC       bool     // Skip the rc files of interactive shells
+	noProfile  bool     // Skip the profiles of login shells
+}
+
+// parseArgs parses the command line. Single-letter options can be grouped
+// as in "-ex" and are disabled with '+'; "-o name" sets a long option.
+func parseArgs(args []string) (*invocation, error) {
+	inv := &invocation{}
+	appInstance := app.GetApp()
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || arg == "-" {
+			i++
+			break
+		}
+		switch arg {
+		case "--login":
+			inv.login = true
+			continue
+		case "--norc":
+			inv.noRC = true
+			continue
+		case "--noprofile":
+			inv.noProfile = true
+			continue
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+		on := arg[0] == '-'
+		for j := 1; j < len(arg); j++ {
+			switch flag := arg[j]; flag {
+			case 'c':
+				if !on {
+					return nil, fmt.Errorf("+c: invalid option")
+				}
+				inv.hasCommand = true
+			case 'l':
+				inv.login = on
+			case 'o':
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("%co: option name required", arg[0])
+				}
+				i++
+				if !app.IsOption(args[i]) {
+					return nil, fmt.Errorf("%s: invalid option name", args[i])
+				}
+				appInstance.SetOption(args[i], on)
+			default:
+				name, ok := app.ShortOptions[flag]
+				if !ok {
+					return nil, fmt.Errorf("%c%c: invalid option", arg[0], flag)
+				}
+				appInstance.SetOption(name, on)
+			}
+		}
+	}
+
+	rest := args[i:]
+	if inv.hasCommand && len(rest) == 0 {
+		return nil, fmt.Errorf("-c: option requires an argument")
+	}
+	if inv.hasCommand {
+		inv.command, rest = rest[0], rest[1:]
+		// The first argument after the command string becomes $0
+		inv.script = "dush"
+		if len(rest) > 0 {
+			inv.script, rest = rest[0], rest[1:]
+		}
+		inv.args = rest
+		return inv, nil
+	}
+	if len(rest) > 0 {
+		inv.script, inv.args = rest[0], rest[1:]
+	}
+	return inv, nil
+}
+
+// startupFiles returns the startup files to source for inv.
+func (inv *invocation) startupFiles(interactive bool) []string {
+	login := inv.login && !inv.noProfile
+	return repl.StartupFiles(login, interactive && !inv.noRC)
+}
+
 func main() {
+	inv, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dush: %v\n%s\n", err, usage)
+		os.Exit(2)
+	}
+	// A login shell is started with a '-' in front of its name
+	if strings.HasPrefix(os.Args[0], "-") {
+		inv.login = true
+	}
+
 	// Bootstrap the application
 	Bootstrap() // Call the bootstrap function without arguments
 
+	switch {
+	case inv.hasCommand:
+		os.Exit(repl.RunString(inv.command, inv.script, inv.args, inv.startupFiles(false), os.Stdout, os.Stderr))
+	case inv.script != "":
+		os.Exit(repl.RunScript(inv.script, inv.args, inv.startupFiles(false), os.Stdout, os.Stderr))
+	}
+
 	fmt.Println("Welcome to dush!")
 	fmt.Println("Type 'exit' or 'quit' to exit.")
-	repl.Start(os.Stdin, os.Stdout, os.Stderr)
+	os.Exit(repl.Start(os.Stdin, os.Stdout, os.Stderr, inv.startupFiles(true)))
 }
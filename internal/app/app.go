@@ -2,13 +2,57 @@ package app
 
 import (
 	"os"
-	"path/filepath"
+	"sort"
 	"sync"
 )
 
+// Shell options that can be toggled with `set -o` / `set +o`.
+const (
+	OptPipefail  = "pipefail"  // A pipeline fails if any of its commands fails
+	OptNoclobber = "noclobber" // '>' refuses to overwrite existing files; '>|' still does
+	OptNoglob    = "noglob"    // Disable pathname expansion
+	OptDotglob   = "dotglob"   // Patterns match files starting with '.'
+	OptNullglob  = "nullglob"  // Patterns matching nothing expand to nothing
+	OptFailglob  = "failglob"  // Patterns matching nothing are an error
+	OptErrexit   = "errexit"   // Exit when a command fails (set -e)
+	OptXtrace    = "xtrace"    // Print commands before running them (set -x)
+	OptNoexec    = "noexec"    // Read commands but do not run them (set -n); scripts only
+	OptMonitor   = "monitor"   // Job control: jobs get their own process group (set -m)
+	OptEmacs     = "emacs"     // Edit command lines with Emacs keys
+	OptVi        = "vi"        // Edit command lines with Vi keys
+)
+
+// optionNames lists every option known to the shell.
+var optionNames = []string{
+	OptPipefail, OptNoclobber, OptNoglob, OptDotglob, OptNullglob, OptFailglob,
+	OptErrexit, OptXtrace, OptNoexec, OptMonitor, OptEmacs, OptVi,
+}
+
+// ShortOptions maps the single-letter flags of `set` and the command line
+// to option names.
+var ShortOptions = map[byte]string{
+	'e': OptErrexit,
+	'x': OptXtrace,
+	'n': OptNoexec,
+	'f': OptNoglob,
+	'C': OptNoclobber,
+	'm': OptMonitor,
+}
+
 // App holds the application's global state.
 type App struct {
-	currentCWD string
+	*Env                     // Variables and working directory of the shell itself
+	mu          sync.RWMutex // Guards the fields below; pipelines access them concurrently
+	options     map[string]bool
+	lastStatus  int    // Exit status of the most recently executed command ($?)
+	scriptName  string // Name of the running script ($0)
+	interactive bool   // Commands are read from the user rather than a script
+	funcs       map[string]*Function
+	traps       map[string]string // Commands registered with `trap`, by condition
+	frame       *Frame            // Positional parameters outside functions
+	jobs        []*Job            // Job table, ordered by job number
+	jobSeq      int               // Counter ordering jobs by when they became current
+	lastBgPid   int               // First process of the last background job ($!)
 }
 
 var (
@@ -21,7 +65,8 @@ var (
 // It ensures that the application state is initialized only once.
 func GetApp() *App {
 	_once.Do(func() {
-		_app = &App{}
+		_app = &App{Env: &Env{}, options: make(map[string]bool), funcs: make(map[string]*Function), traps: make(map[string]string), scriptName: "dush", frame: NewFrame(nil, nil)}
+		_app.Env.initVars()
 		// Initialize currentCWD with the actual OS CWD at startup
 		initialCWD, err := os.Getwd()
 		if err != nil {
@@ -33,16 +78,84 @@ func GetApp() *App {
 	return _app
 }
 
-// GetCurrentDir returns the shell's current working directory.
-func (a *App) GetCurrentDir() string {
-	return a.currentCWD
+// OptionNames returns the sorted names of all shell options.
+func OptionNames() []string {
+	names := append([]string(nil), optionNames...)
+	sort.Strings(names)
+	return names
+}
+
+// IsOption reports whether name is a known shell option.
+func IsOption(name string) bool {
+	for _, n := range optionNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Option reports whether the named shell option is enabled.
+func (a *App) Option(name string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.options[name]
+}
+
+// SetOption enables or disables the named shell option.
+// Callers are expected to validate the name with IsOption.
+func (a *App) SetOption(name string, on bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.options[name] = on
+	// Only one editing mode is on at a time
+	switch {
+	case on && name == OptEmacs:
+		a.options[OptVi] = false
+	case on && name == OptVi:
+		a.options[OptEmacs] = false
+	}
+}
+
+// LastStatus returns the exit status of the most recently executed command.
+func (a *App) LastStatus() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.lastStatus
+}
+
+// SetLastStatus records the exit status of the most recently executed command.
+func (a *App) SetLastStatus(status int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastStatus = status
+}
+
+// ScriptName returns the name of the running script, or "dush" in an
+// interactive session.
+func (a *App) ScriptName() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.scriptName
+}
+
+// SetScriptName sets the value of $0.
+func (a *App) SetScriptName(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.scriptName = name
+}
+
+// Interactive reports whether the shell reads commands from the user.
+func (a *App) Interactive() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.interactive
 }
 
-// SetCurrentDir sets the shell's current working directory.
-// It performs path cleaning but does not check if the path exists or is a directory.
-// This check should be done by the caller (e.g., the 'cd' builtin).
-func (a *App) SetCurrentDir(path string) error {
-	cleanedPath := filepath.Clean(path)
-	a.currentCWD = cleanedPath
-	return nil // No error for setting, validation done by caller
+// SetInteractive records whether the shell reads commands from the user.
+func (a *App) SetInteractive(interactive bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.interactive = interactive
 }
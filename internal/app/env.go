@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"maps"
+	"path/filepath"
+	"sync"
+)
+
+// Env holds the variables and working directory of the shell or of a
+// subshell. Subshells, such as the stages of a pipeline, work on a copy
+// carried by their context, so that what they change does not reach the
+// shell.
+type Env struct {
+	mu         sync.RWMutex
+	currentCWD string
+	previousWD string // Directory before the last change, used by `cd -`
+	vars       map[string]Variable
+}
+
+// envKey is the context key under which the environment is stored.
+type envKey struct{}
+
+// WithEnv returns a copy of ctx carrying the environment env.
+func WithEnv(ctx context.Context, env *Env) context.Context {
+	return context.WithValue(ctx, envKey{}, env)
+}
+
+// CurrentEnv returns the environment stored in ctx, or that of the shell.
+func CurrentEnv(ctx context.Context) *Env {
+	if env, ok := ctx.Value(envKey{}).(*Env); ok {
+		return env
+	}
+	return GetApp().Env
+}
+
+// Clone returns a copy of the environment for a subshell.
+func (e *Env) Clone() *Env {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return &Env{currentCWD: e.currentCWD, previousWD: e.previousWD, vars: maps.Clone(e.vars)}
+}
+
+// GetCurrentDir returns the shell's current working directory.
+func (e *Env) GetCurrentDir() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.currentCWD
+}
+
+// SetCurrentDir sets the shell's current working directory.
+// It performs path cleaning but does not check if the path exists or is a directory.
+// This check should be done by the caller (e.g., the 'cd' builtin).
+// The directory being left is remembered as the previous directory.
+func (e *Env) SetCurrentDir(path string) error {
+	cleanedPath := filepath.Clean(path)
+	e.mu.Lock()
+	if e.currentCWD != "" && e.currentCWD != cleanedPath {
+		e.previousWD = e.currentCWD
+		e.vars["OLDPWD"] = Variable{Value: e.previousWD, Exported: true}
+	}
+	e.currentCWD = cleanedPath
+	e.vars["PWD"] = Variable{Value: cleanedPath, Exported: true}
+	e.mu.Unlock()
+	return nil // No error for setting, validation done by caller
+}
+
+// GetPreviousDir returns the directory the shell was in before the last
+// directory change, or "" if it has not changed yet.
+func (e *Env) GetPreviousDir() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.previousWD
+}
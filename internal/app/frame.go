@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// Frame holds the positional parameters of the shell or of a function
+// call, and the variables its `local` declarations shadowed. Frames are
+// carried by the context of the commands they run, so that functions
+// running at the same time, as in the stages of a pipeline, each have
+// their own.
+type Frame struct {
+	mu     sync.Mutex
+	params []string
+	env    *Env                     // Where `local` declarations are made; nil outside functions
+	saved  map[string]savedVariable // Variables shadowed by `local`
+}
+
+// frameKey is the context key under which the frame is stored.
+type frameKey struct{}
+
+// NewFrame returns a frame with the positional parameters params. The
+// frame of a function call is given the environment it runs in, whose
+// variables `local` shadows; that of the shell itself has none.
+func NewFrame(params []string, env *Env) *Frame {
+	return &Frame{params: append([]string(nil), params...), env: env, saved: make(map[string]savedVariable)}
+}
+
+// WithFrame returns a copy of ctx carrying the frame f.
+func WithFrame(ctx context.Context, f *Frame) context.Context {
+	return context.WithValue(ctx, frameKey{}, f)
+}
+
+// CurrentFrame returns the frame stored in ctx, or that of the shell
+// outside functions.
+func CurrentFrame(ctx context.Context) *Frame {
+	if f, ok := ctx.Value(frameKey{}).(*Frame); ok {
+		return f
+	}
+	return GetApp().frame
+}
+
+// Clone returns a copy of the frame for a subshell running in env, the
+// copy of the environment of f.
+func (f *Frame) Clone(env *Env) *Frame {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := &Frame{params: append([]string(nil), f.params...), saved: maps.Clone(f.saved)}
+	if f.env != nil {
+		c.env = env
+	}
+	return c
+}
+
+// Params returns a copy of the positional parameters.
+func (f *Frame) Params() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.params...)
+}
+
+// SetParams replaces the positional parameters.
+func (f *Frame) SetParams(params []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.params = append([]string(nil), params...)
+}
+
+// InFunction reports whether f is the frame of a function call.
+func (f *Frame) InFunction() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.env != nil
+}
+
+// DeclareLocal makes name local to the function call. The variable starts
+// out unset and unexported; its previous state is restored by
+// RestoreLocals. It reports false outside functions.
+func (f *Frame) DeclareLocal(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.env == nil {
+		return false
+	}
+	if _, ok := f.saved[name]; ok {
+		return true
+	}
+	v, isSet := f.env.LookupVar(name)
+	f.saved[name] = savedVariable{v: v, isSet: isSet}
+	f.env.UnsetVar(name)
+	return true
+}
+
+// RestoreLocals restores every variable the `local` declarations of the
+// function call shadowed, as the call returns.
+func (f *Frame) RestoreLocals() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name, saved := range f.saved {
+		f.env.RestoreVar(name, saved.v, saved.isSet)
+	}
+	clear(f.saved)
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"sort"
+
+	"dush/internal/parser"
+)
+
+// Function is a shell function defined with `name() { ...; }`.
+type Function struct {
+	Body   parser.Command
+	Source string // The definition as written, shown by `type` and `declare -f`
+}
+
+// savedVariable is the state of a variable before `local` shadowed it.
+type savedVariable struct {
+	v     Variable
+	isSet bool
+}
+
+// GetFunc returns the shell function with the given name.
+func (a *App) GetFunc(name string) (*Function, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	fn, ok := a.funcs[name]
+	return fn, ok
+}
+
+// SetFunc defines or redefines a shell function.
+func (a *App) SetFunc(name string, fn *Function) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.funcs[name] = fn
+}
+
+// UnsetFunc removes a shell function and reports whether it existed.
+func (a *App) UnsetFunc(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.funcs[name]
+	delete(a.funcs, name)
+	return ok
+}
+
+// FuncNames returns the sorted names of all shell functions.
+func (a *App) FuncNames() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.funcs))
+	for name := range a.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,372 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JobState is the state of a job.
+type JobState int
+
+const (
+	JobRunning JobState = iota
+	JobStopped
+	JobDone
+)
+
+// String returns the state as shown by `jobs`.
+func (s JobState) String() string {
+	switch s {
+	case JobStopped:
+		return "Stopped"
+	case JobDone:
+		return "Done"
+	}
+	return "Running"
+}
+
+// Job is a command run by the shell as a unit of job control: a background
+// command, or a foreground command that can be stopped with Ctrl-Z. Its
+// external processes share one process group when job control is enabled.
+type Job struct {
+	Command string // Source text of the command
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	id         int // Number in the job table, or 0 if not in it
+	pgid       int
+	pids       []int // Processes started so far, in order
+	running    map[int]bool
+	state      JobState
+	status     int
+	foreground bool
+	changed    bool // The state changed since it was last reported
+	used       int  // Sequence number of the last time the job became current
+	launched   chan struct{}
+	launchOnce sync.Once
+	done       chan struct{}
+}
+
+// NewJob returns a running job for command. It is not in the job table
+// until added with AddJob.
+func NewJob(command string, foreground bool) *Job {
+	j := &Job{
+		Command:    command,
+		running:    make(map[int]bool),
+		foreground: foreground,
+		launched:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	j.cond = sync.NewCond(&j.mu)
+	return j
+}
+
+// ID returns the job number, or 0 if the job is not in the job table.
+func (j *Job) ID() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.id
+}
+
+// Pgid returns the process group of the job, or 0 if it has none.
+func (j *Job) Pgid() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.pgid
+}
+
+// Pids returns the processes started by the job, in order.
+func (j *Job) Pids() []int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]int(nil), j.pids...)
+}
+
+// StartProcess starts a process of the job with start, which receives the
+// process group of the job, 0 if it has none yet, and returns the new pid
+// and the group the process is in, 0 without job control. Starts are
+// serialized so that only the first process creates a group.
+func (j *Job) StartProcess(start func(pgid int) (pid, newPgid int, err error)) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	pid, pgid, err := start(j.pgid)
+	if err != nil {
+		return 0, err
+	}
+	j.pgid = pgid
+	j.pids = append(j.pids, pid)
+	j.running[pid] = true
+	j.markLaunched()
+	return pid, nil
+}
+
+// ProcessDone records that a process of the job has exited.
+func (j *Job) ProcessDone(pid int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.running, pid)
+}
+
+// Launched returns a channel closed once the job has started its first
+// process or begun running a builtin or function.
+func (j *Job) Launched() <-chan struct{} {
+	return j.launched
+}
+
+// MarkLaunched records that the job has begun running commands.
+func (j *Job) MarkLaunched() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.markLaunched()
+}
+
+func (j *Job) markLaunched() {
+	j.launchOnce.Do(func() { close(j.launched) })
+}
+
+// Foreground reports whether the job runs in the foreground.
+func (j *Job) Foreground() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.foreground
+}
+
+// SetForeground moves the job to the foreground or the background.
+func (j *Job) SetForeground(fg bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.foreground = fg
+}
+
+// State returns the state of the job.
+func (j *Job) State() JobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// SetState marks a job that has not finished as running or stopped.
+func (j *Job) SetState(state JobState) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state == JobDone || j.state == state {
+		return
+	}
+	j.state = state
+	j.changed = true
+	j.cond.Broadcast()
+}
+
+// Finish marks the job as done with the given exit status.
+func (j *Job) Finish(status int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state = JobDone
+	j.status = status
+	j.changed = true
+	j.markLaunched()
+	close(j.done)
+	j.cond.Broadcast()
+}
+
+// Status returns the exit status of a finished job.
+func (j *Job) Status() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// Done returns a channel closed when the job finishes.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// WaitChange blocks while the job is running and returns its new state.
+func (j *Job) WaitChange() JobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for j.state == JobRunning {
+		j.cond.Wait()
+	}
+	return j.state
+}
+
+// RunningPids returns the processes of the job that have not exited.
+func (j *Job) RunningPids() []int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var pids []int
+	for _, pid := range j.pids {
+		if j.running[pid] {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// takeChange reports whether the state changed since the last call.
+func (j *Job) takeChange() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	changed := j.changed
+	j.changed = false
+	return changed
+}
+
+// AddJob adds j to the job table with the next free number and makes it
+// the current job.
+func (a *App) AddJob(j *Job) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	id := 1
+	for _, other := range a.jobs {
+		id = max(id, other.id+1)
+	}
+	j.mu.Lock()
+	j.id = id
+	j.mu.Unlock()
+	a.jobs = append(a.jobs, j)
+	a.touchJob(j)
+	return id
+}
+
+// MakeCurrentJob makes j the current job, %+, as when it is stopped or
+// moved to the background.
+func (a *App) MakeCurrentJob(j *Job) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.touchJob(j)
+}
+
+func (a *App) touchJob(j *Job) {
+	a.jobSeq++
+	j.mu.Lock()
+	j.used = a.jobSeq
+	j.mu.Unlock()
+}
+
+// RemoveJob removes j from the job table.
+func (a *App) RemoveJob(j *Job) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, other := range a.jobs {
+		if other == j {
+			a.jobs = append(a.jobs[:i], a.jobs[i+1:]...)
+			break
+		}
+	}
+	j.mu.Lock()
+	j.id = 0
+	j.mu.Unlock()
+}
+
+// Jobs returns the jobs in the job table, ordered by number.
+func (a *App) Jobs() []*Job {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]*Job(nil), a.jobs...)
+}
+
+// ChangedJobs returns the jobs whose state changed since they were last
+// reported, ordered by number, and clears their changed flag.
+func (a *App) ChangedJobs() []*Job {
+	var changed []*Job
+	for _, j := range a.Jobs() {
+		if j.takeChange() {
+			changed = append(changed, j)
+		}
+	}
+	return changed
+}
+
+// CurrentJobs returns the current job, %+, and the previous one, %-.
+// Either is nil if there are not enough jobs.
+func (a *App) CurrentJobs() (current, previous *Job) {
+	jobs := a.Jobs()
+	sort.SliceStable(jobs, func(i, k int) bool {
+		return jobs[i].usedSeq() > jobs[k].usedSeq()
+	})
+	if len(jobs) > 0 {
+		current = jobs[0]
+	}
+	if len(jobs) > 1 {
+		previous = jobs[1]
+	}
+	return current, previous
+}
+
+func (j *Job) usedSeq() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.used
+}
+
+// FindJob resolves a job spec: %N or N for job number N, %+, %% or % for
+// the current job, %- for the previous one, %name for the job whose
+// command starts with name and %?text for the one containing text.
+func (a *App) FindJob(spec string) (*Job, error) {
+	rest := strings.TrimPrefix(spec, "%")
+	current, previous := a.CurrentJobs()
+	switch rest {
+	case "", "+", "%":
+		if current == nil {
+			return nil, fmt.Errorf("%s: no current job", spec)
+		}
+		return current, nil
+	case "-":
+		if previous == nil {
+			return nil, fmt.Errorf("%s: no previous job", spec)
+		}
+		return previous, nil
+	}
+	if n, err := strconv.Atoi(rest); err == nil {
+		for _, j := range a.Jobs() {
+			if j.ID() == n {
+				return j, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+	if spec == rest {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	var found *Job
+	for _, j := range a.Jobs() {
+		var ok bool
+		if text, contains := strings.CutPrefix(rest, "?"); contains {
+			ok = strings.Contains(j.Command, text)
+		} else {
+			ok = strings.HasPrefix(j.Command, rest)
+		}
+		if !ok {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s: ambiguous job spec", spec)
+		}
+		found = j
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+	return found, nil
+}
+
+// LastBackgroundPid returns the value of $!, the first process of the most
+// recent background job.
+func (a *App) LastBackgroundPid() (int, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.lastBgPid, a.lastBgPid != 0
+}
+
+// SetLastBackgroundPid sets the value of $!.
+func (a *App) SetLastBackgroundPid(pid int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastBgPid = pid
+}
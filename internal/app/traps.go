@@ -0,0 +1,47 @@
+package app
+
+import "sort"
+
+// Shell events that can be trapped besides signals.
+const (
+	TrapExit   = "EXIT"   // The shell exits
+	TrapErr    = "ERR"    // A command fails
+	TrapDebug  = "DEBUG"  // Before each command
+	TrapReturn = "RETURN" // A function or sourced file returns
+)
+
+// Trap returns the command registered with `trap` for a condition: an
+// event such as EXIT or a signal name without the SIG prefix, like INT.
+// An empty command means the signal is ignored.
+func (a *App) Trap(name string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	action, ok := a.traps[name]
+	return action, ok
+}
+
+// SetTrap registers the command to run for a condition.
+func (a *App) SetTrap(name, action string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.traps[name] = action
+}
+
+// ResetTrap removes the trap for a condition.
+func (a *App) ResetTrap(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.traps, name)
+}
+
+// TrapNames returns the sorted names of the conditions with a trap.
+func (a *App) TrapNames() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.traps))
+	for name := range a.traps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
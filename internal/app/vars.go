@@ -0,0 +1,117 @@
+package app
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Variable is a shell variable.
+type Variable struct {
+	Value    string
+	Exported bool // Passed to child processes in their environment
+}
+
+// initVars seeds the variable store from the process environment.
+// Everything inherited from the environment is exported.
+func (e *Env) initVars() {
+	e.vars = make(map[string]Variable)
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			continue
+		}
+		e.vars[name] = Variable{Value: value, Exported: true}
+	}
+}
+
+// GetVar returns the value of a shell variable and whether it is set.
+func (e *Env) GetVar(name string) (string, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	v, ok := e.vars[name]
+	return v.Value, ok
+}
+
+// LookupVar returns the full variable, including whether it is exported.
+func (e *Env) LookupVar(name string) (Variable, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	v, ok := e.vars[name]
+	return v, ok
+}
+
+// SetVar sets the value of a shell variable, keeping its exported state.
+// New variables are local to the shell until exported.
+func (e *Env) SetVar(name, value string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	v := e.vars[name]
+	v.Value = value
+	e.vars[name] = v
+}
+
+// ExportVar marks a variable as exported, creating it empty if it is unset.
+func (e *Env) ExportVar(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	v := e.vars[name]
+	v.Exported = true
+	e.vars[name] = v
+}
+
+// UnexportVar keeps a variable but stops passing it to child processes.
+func (e *Env) UnexportVar(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if v, ok := e.vars[name]; ok {
+		v.Exported = false
+		e.vars[name] = v
+	}
+}
+
+// UnsetVar removes a shell variable.
+func (e *Env) UnsetVar(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.vars, name)
+}
+
+// RestoreVar puts back a variable saved with LookupVar, or removes it if it
+// was not set. It is used to undo temporary assignments like `FOO=1 cmd`.
+func (e *Env) RestoreVar(name string, v Variable, wasSet bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if wasSet {
+		e.vars[name] = v
+	} else {
+		delete(e.vars, name)
+	}
+}
+
+// VarNames returns the sorted names of all shell variables.
+func (e *Env) VarNames() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	names := make([]string, 0, len(e.vars))
+	for name := range e.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environ returns the exported variables as sorted "NAME=value" pairs,
+// suitable for exec.Cmd.Env.
+func (e *Env) Environ() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	env := make([]string, 0, len(e.vars))
+	for name, v := range e.vars {
+		if v.Exported {
+			env = append(env, name+"="+v.Value)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,503 @@
+// Package arith evaluates shell arithmetic expressions, as used by
+// $((...)), ((...)) and let.
+package arith
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dush/internal/app"
+)
+
+// maxDepth limits how deeply variables holding expressions are evaluated.
+const maxDepth = 1024
+
+// Error is an error in an arithmetic expression.
+type Error struct {
+	Expr string
+	Msg  string
+	Tok  string // The token the error was detected at, if any
+}
+
+func (e *Error) Error() string {
+	if e.Tok == "" {
+		return fmt.Sprintf("%s: %s", e.Expr, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s (error token is \"%s\")", e.Expr, e.Msg, e.Tok)
+}
+
+// operand is the result of evaluating a subexpression. name is set when it
+// is a bare variable, which can be assigned to.
+type operand struct {
+	val  int64
+	name string
+}
+
+// evaluator evaluates an expression while parsing it. Shell variables are
+// read and written in env.
+type evaluator struct {
+	env   *app.Env
+	src   string
+	off   int
+	tok   string // Current operator, or "num"/"name"/"" for operands and the end
+	tokOf int    // Offset of the current token
+	num   int64  // Set when tok == "num"
+	name  string // Set when tok == "name"
+	skip  int    // >0 inside a branch that is parsed but not evaluated
+	depth int
+}
+
+// Eval evaluates expr with 64-bit signed integers and the C operators,
+// plus '**' for exponentiation. Variables are read as numbers, evaluating
+// their values as expressions if needed; unset or empty variables are 0.
+// An empty expression evaluates to 0. The variables are those of the
+// environment of ctx.
+func Eval(ctx context.Context, expr string) (int64, error) {
+	return eval(app.CurrentEnv(ctx), expr, 0)
+}
+
+func eval(env *app.Env, expr string, depth int) (n int64, err error) {
+	if depth > maxDepth {
+		return 0, &Error{Expr: strings.TrimSpace(expr), Msg: "expression recursion level exceeded"}
+	}
+	e := &evaluator{env: env, src: expr, depth: depth}
+	defer func() {
+		if r := recover(); r != nil {
+			arithErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			n, err = 0, arithErr
+		}
+	}()
+	e.next()
+	if e.tok == "" {
+		return 0, nil
+	}
+	x := e.comma()
+	if e.tok != "" {
+		e.fail("syntax error in expression")
+	}
+	return x.val, nil
+}
+
+// fail aborts the evaluation with an error at the current token.
+func (e *evaluator) fail(msg string) {
+	panic(&Error{Expr: strings.TrimSpace(e.src), Msg: msg, Tok: strings.TrimSpace(e.src[e.tokOf:])})
+}
+
+// operators lists the operator tokens, longest first.
+var operators = []string{
+	"<<=", ">>=", "**",
+	"++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"+=", "-=", "*=", "/=", "%=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "^", "|", "!", "~", "?", ":", "=", "(", ")", ",",
+}
+
+// next advances to the next token.
+func (e *evaluator) next() {
+	for e.off < len(e.src) && strings.IndexByte(" \t\n\r", e.src[e.off]) >= 0 {
+		e.off++
+	}
+	e.tokOf = e.off
+	if e.off >= len(e.src) {
+		e.tok = ""
+		return
+	}
+	c := e.src[e.off]
+	switch {
+	case c >= '0' && c <= '9':
+		start := e.off
+		for e.off < len(e.src) && (isAlnum(e.src[e.off]) || strings.IndexByte("#@_", e.src[e.off]) >= 0) {
+			e.off++
+		}
+		e.tok = "num"
+		e.num = e.parseNumber(e.src[start:e.off])
+		return
+	case c == '_' || isAlpha(c):
+		start := e.off
+		for e.off < len(e.src) && (isAlnum(e.src[e.off]) || e.src[e.off] == '_') {
+			e.off++
+		}
+		e.tok = "name"
+		e.name = e.src[start:e.off]
+		return
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(e.src[e.off:], op) {
+			e.off += len(op)
+			e.tok = op
+			return
+		}
+	}
+	e.fail("syntax error: invalid arithmetic operator")
+}
+
+// parseNumber parses a decimal, octal (0 prefix), hexadecimal (0x prefix)
+// or base#digits constant, with bases from 2 to 64.
+func (e *evaluator) parseNumber(s string) int64 {
+	base := 10
+	digits := s
+	switch {
+	case strings.Contains(s, "#"):
+		b, rest, _ := strings.Cut(s, "#")
+		n, err := strconv.Atoi(b)
+		if err != nil || n < 2 || n > 64 {
+			e.fail("invalid arithmetic base")
+		}
+		base, digits = n, rest
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		base, digits = 16, s[2:]
+	case len(s) > 1 && s[0] == '0':
+		base, digits = 8, s[1:]
+	}
+	if digits == "" {
+		e.fail("invalid number")
+	}
+	var n int64
+	for i := 0; i < len(digits); i++ {
+		d := digitValue(digits[i], base)
+		if d < 0 || d >= base {
+			e.fail("value too great for base")
+		}
+		n = n*int64(base) + int64(d)
+	}
+	return n
+}
+
+// digitValue returns the value of a digit in bases up to 64: 0-9, a-z,
+// A-Z, '@' and '_'. Up to base 36 letters are case-insensitive.
+func digitValue(c byte, base int) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'Z':
+		if base <= 36 {
+			return int(c-'A') + 10
+		}
+		return int(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
+
+func isAlpha(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isAlnum(c byte) bool { return isAlpha(c) || (c >= '0' && c <= '9') }
+
+// expect consumes the operator tok or fails.
+func (e *evaluator) expect(tok string) {
+	if e.tok != tok {
+		e.fail(fmt.Sprintf("'%s' expected", tok))
+	}
+	e.next()
+}
+
+// value returns the current value of a variable.
+func (e *evaluator) value(name string) int64 {
+	s, _ := e.env.GetVar(name)
+	s = strings.TrimSpace(s)
+	if s == "" || e.skip > 0 {
+		return 0
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	n, err := eval(e.env, s, e.depth+1)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// assign sets a variable unless evaluation is being skipped.
+func (e *evaluator) assign(name string, n int64) int64 {
+	if e.skip == 0 {
+		e.env.SetVar(name, strconv.FormatInt(n, 10))
+	}
+	return n
+}
+
+// comma parses expressions separated by ',', returning the last one.
+func (e *evaluator) comma() operand {
+	x := e.assignment()
+	for e.tok == "," {
+		e.next()
+		x = e.assignment()
+	}
+	return x
+}
+
+// assignOps maps compound assignment operators to their binary operator.
+var assignOps = map[string]string{
+	"=": "", "+=": "+", "-=": "-", "*=": "*", "/=": "/", "%=": "%",
+	"<<=": "<<", ">>=": ">>", "&=": "&", "^=": "^", "|=": "|",
+}
+
+// assignment parses an assignment, which is right-associative.
+func (e *evaluator) assignment() operand {
+	x := e.ternary()
+	op, ok := assignOps[e.tok]
+	if !ok {
+		return x
+	}
+	if x.name == "" {
+		e.fail("attempted assignment to non-variable")
+	}
+	e.next()
+	y := e.assignment()
+	n := y.val
+	if op != "" {
+		n = e.binary(op, e.value(x.name), y.val)
+	}
+	return operand{val: e.assign(x.name, n)}
+}
+
+// ternary parses cond ? x : y, evaluating only the branch taken.
+func (e *evaluator) ternary() operand {
+	cond := e.logicalOr()
+	if e.tok != "?" {
+		return cond
+	}
+	e.next()
+	if cond.val == 0 {
+		e.skip++
+	}
+	x := e.comma()
+	if cond.val == 0 {
+		e.skip--
+	}
+	e.expect(":")
+	if cond.val != 0 {
+		e.skip++
+	}
+	y := e.ternary()
+	if cond.val != 0 {
+		e.skip--
+		return operand{val: x.val}
+	}
+	return operand{val: y.val}
+}
+
+// logicalOr parses '||', short-circuiting the right side.
+func (e *evaluator) logicalOr() operand {
+	x := e.logicalAnd()
+	for e.tok == "||" {
+		e.next()
+		if x.val != 0 {
+			e.skip++
+		}
+		y := e.logicalAnd()
+		if x.val != 0 {
+			e.skip--
+		}
+		x = operand{val: boolInt(x.val != 0 || y.val != 0)}
+	}
+	return x
+}
+
+// logicalAnd parses '&&', short-circuiting the right side.
+func (e *evaluator) logicalAnd() operand {
+	x := e.binaryLevel(0)
+	for e.tok == "&&" {
+		e.next()
+		if x.val == 0 {
+			e.skip++
+		}
+		y := e.binaryLevel(0)
+		if x.val == 0 {
+			e.skip--
+		}
+		x = operand{val: boolInt(x.val != 0 && y.val != 0)}
+	}
+	return x
+}
+
+// precedence lists the left-associative binary operators from the lowest
+// precedence to the highest.
+var precedence = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", ">", "<=", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// binaryLevel parses the binary operators of precedence[level] and above.
+func (e *evaluator) binaryLevel(level int) operand {
+	if level == len(precedence) {
+		return e.power()
+	}
+	x := e.binaryLevel(level + 1)
+	for contains(precedence[level], e.tok) {
+		op := e.tok
+		e.next()
+		y := e.binaryLevel(level + 1)
+		x = operand{val: e.binary(op, x.val, y.val)}
+	}
+	return x
+}
+
+// power parses '**', which is right-associative.
+func (e *evaluator) power() operand {
+	x := e.unary()
+	if e.tok != "**" {
+		return x
+	}
+	e.next()
+	y := e.power()
+	return operand{val: e.binary("**", x.val, y.val)}
+}
+
+// unary parses the prefix operators.
+func (e *evaluator) unary() operand {
+	switch op := e.tok; op {
+	case "+", "-", "!", "~":
+		e.next()
+		x := e.unary()
+		switch op {
+		case "-":
+			return operand{val: -x.val}
+		case "!":
+			return operand{val: boolInt(x.val == 0)}
+		case "~":
+			return operand{val: ^x.val}
+		}
+		return operand{val: x.val}
+	case "++", "--":
+		e.next()
+		if e.tok != "name" {
+			e.fail("syntax error: operand expected")
+		}
+		name := e.name
+		e.next()
+		n := e.value(name)
+		if op == "++" {
+			n++
+		} else {
+			n--
+		}
+		return operand{val: e.assign(name, n)}
+	}
+	return e.postfix()
+}
+
+// postfix parses an operand with an optional '++' or '--' suffix.
+func (e *evaluator) postfix() operand {
+	switch e.tok {
+	case "num":
+		n := e.num
+		e.next()
+		return operand{val: n}
+	case "name":
+		name := e.name
+		e.next()
+		n := e.value(name)
+		switch e.tok {
+		case "++":
+			e.next()
+			e.assign(name, n+1)
+			return operand{val: n}
+		case "--":
+			e.next()
+			e.assign(name, n-1)
+			return operand{val: n}
+		}
+		return operand{val: n, name: name}
+	case "(":
+		e.next()
+		x := e.comma()
+		e.expect(")")
+		return operand{val: x.val}
+	}
+	e.fail("syntax error: operand expected")
+	return operand{}
+}
+
+// binary applies a binary operator. Division by zero is an error unless
+// the expression is being skipped.
+func (e *evaluator) binary(op string, x, y int64) int64 {
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/", "%":
+		if y == 0 {
+			if e.skip > 0 {
+				return 0
+			}
+			e.fail("division by 0")
+		}
+		if op == "/" {
+			return x / y
+		}
+		return x % y
+	case "**":
+		if y < 0 {
+			if e.skip > 0 {
+				return 0
+			}
+			e.fail("exponent less than 0")
+		}
+		// Exponentiation by squaring
+		n := int64(1)
+		for ; y > 0; y >>= 1 {
+			if y&1 == 1 {
+				n *= x
+			}
+			x *= x
+		}
+		return n
+	case "<<":
+		return x << uint64(y&63)
+	case ">>":
+		return x >> uint64(y&63)
+	case "&":
+		return x & y
+	case "^":
+		return x ^ y
+	case "|":
+		return x | y
+	case "==":
+		return boolInt(x == y)
+	case "!=":
+		return boolInt(x != y)
+	case "<":
+		return boolInt(x < y)
+	case ">":
+		return boolInt(x > y)
+	case "<=":
+		return boolInt(x <= y)
+	case ">=":
+		return boolInt(x >= y)
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package arith
+
+import (
+	"context"
+	"testing"
+
+	"dush/internal/app"
+)
+
+// evalIn evaluates expr with the variables vars set in a copy of the
+// shell's variables, which it returns.
+func evalIn(expr string, vars map[string]string) (int64, *app.Env, error) {
+	env := app.CurrentEnv(context.Background()).Clone()
+	for name, value := range vars {
+		env.SetVar(name, value)
+	}
+	n, err := Eval(app.WithEnv(context.Background(), env), expr)
+	return n, env, err
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int64
+	}{
+		{"", 0},
+		{"  42 ", 42},
+		{"010 + 0x1f + 0X10 + 2#101 + 64#_ + 36#Z", 8 + 31 + 16 + 5 + 63 + 35},
+
+		// Precedence and associativity
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"100 / 10 / 5", 2},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", 4},
+		{"7 % 3 * 2", 2},
+		{"1 + 2 << 1", 6},
+		{"1 << 2 + 1", 8},
+		{"5 & 3 == 3", 1},
+		{"1 | 2 ^ 3 & 1", 3},
+		{"1 < 2 == 1", 1},
+		{"0 || 2 && 0", 0},
+		{"1 ? 2 : 3 ? 4 : 5", 2},
+		{"0 ? 2 : 0 ? 4 : 5", 5},
+		{"1, 2, 3", 3},
+
+		// Unary operators
+		{"-5 + +3", -2},
+		{"!0 + !7", 1},
+		{"~0", -1},
+		{"- -1", 1},
+
+		// Division and bit operations
+		{"-7 / 2", -3},
+		{"-7 % 2", -1},
+		{"-8 >> 1", -4},
+		{"1 << 64", 1},
+		{"9223372036854775807 + 1", -9223372036854775808},
+
+		// Short-circuiting skips division by zero
+		{"0 && 1 / 0", 0},
+		{"1 || 1 / 0", 1},
+		{"1 ? 1 : 1 / 0", 1},
+	}
+	for _, tt := range tests {
+		got, _, err := evalIn(tt.expr, nil)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q): got %d, want %d", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+	}{
+		{"1 / 0", "1 / 0: division by 0"},
+		{"5 % 0", "5 % 0: division by 0"},
+		{"x /= 0", "x /= 0: division by 0"},
+		{"2 ** -1", "2 ** -1: exponent less than 0"},
+		{"1 +", "1 +: syntax error: operand expected"},
+		{"(1", "(1: ')' expected"},
+		{"1 2", "1 2: syntax error in expression (error token is \"2\")"},
+		{"1 = 2", "1 = 2: attempted assignment to non-variable (error token is \"= 2\")"},
+		{"08", "08: value too great for base (error token is \"08\")"},
+		{"65#1", "65#1: invalid arithmetic base (error token is \"65#1\")"},
+		{"1 $ 2", "1 $ 2: syntax error: invalid arithmetic operator (error token is \"$ 2\")"},
+		{"loop", "loop: expression recursion level exceeded"},
+	}
+	for _, tt := range tests {
+		_, _, err := evalIn(tt.expr, map[string]string{"x": "1", "loop": "loop"})
+		if err == nil {
+			t.Errorf("Eval(%q): got no error, want %q", tt.expr, tt.msg)
+			continue
+		}
+		if err.Error() != tt.msg {
+			t.Errorf("Eval(%q): got error %q, want %q", tt.expr, err, tt.msg)
+		}
+	}
+}
+
+func TestEvalVariables(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int64
+		vars map[string]string // Values after the evaluation
+	}{
+		{"x + y * 2", 21, nil},
+		{"unset_var + empty + 1", 1, nil},
+		{"expr * 2", 14, nil},
+		{"a = 5", 5, map[string]string{"a": "5"}},
+		{"a = b = x", 1, map[string]string{"a": "1", "b": "1"}},
+		{"x += 4", 5, map[string]string{"x": "5"}},
+		{"y -= 4", 6, map[string]string{"y": "6"}},
+		{"y *= 3", 30, map[string]string{"y": "30"}},
+		{"y /= 3", 3, map[string]string{"y": "3"}},
+		{"y %= 3", 1, map[string]string{"y": "1"}},
+		{"x <<= 4", 16, map[string]string{"x": "16"}},
+		{"y >>= 1", 5, map[string]string{"y": "5"}},
+		{"y &= 6", 2, map[string]string{"y": "2"}},
+		{"y ^= 6", 12, map[string]string{"y": "12"}},
+		{"y |= 5", 15, map[string]string{"y": "15"}},
+		{"x++ + x", 3, map[string]string{"x": "2"}},
+		{"++x + x", 4, map[string]string{"x": "2"}},
+		{"x-- - x", 1, map[string]string{"x": "0"}},
+		{"--x", 0, map[string]string{"x": "0"}},
+		{"0 && (a = 1)", 0, map[string]string{"a": ""}},
+		{"1 ? (a = 2) : (b = 3)", 2, map[string]string{"a": "2", "b": ""}},
+	}
+	for _, tt := range tests {
+		got, env, err := evalIn(tt.expr, map[string]string{"x": "1", "y": "10", "empty": "", "expr": "x + 6", "a": "", "b": ""})
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q): got %d, want %d", tt.expr, got, tt.want)
+		}
+		for name, want := range tt.vars {
+			if value, _ := env.GetVar(name); value != want {
+				t.Errorf("Eval(%q): got %s=%q, want %q", tt.expr, name, value, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"dush/internal/utils"
+)
+
+// Binder gives 'bind' access to the keymaps of the line editor, which
+// this package cannot import.
+type Binder interface {
+	// Bind binds the key seq, such as "C-a" or "M-f", to the editing
+	// function fn in the named keymap, or unbinds it if fn is empty. The
+	// keymap of the current editing mode is used when name is empty.
+	Bind(name, seq, fn string) error
+	// Bindings returns the editing functions of the keys bound in the
+	// named keymap.
+	Bindings(name string) (map[string]string, error)
+	// Functions returns the names of the editing functions.
+	Functions() []string
+}
+
+// binder is set by the REPL.
+var binder Binder
+
+// SetBinder registers the keymaps 'bind' changes.
+func SetBinder(b Binder) {
+	binder = b
+}
+
+// BindCommand implements the 'bind' builtin.
+type BindCommand struct{}
+
+// Execute binds keys of the line editor to editing functions.
+//
+// `bind key function` binds a key and `bind -r key` unbinds it. Without
+// arguments or with -p the bindings are printed; -l lists the functions.
+// -m selects the keymap: emacs, vi-insert or vi-command.
+func (c *BindCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	const usage = "Usage: bind [-m keymap] [-lp] [-r key] [key function]"
+	if binder == nil {
+		return fmt.Errorf("line editing is not available")
+	}
+
+	keymap, printOnly := "", false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		flag := args[0]
+		args = args[1:]
+		switch flag {
+		case "--":
+		case "-l":
+			for _, name := range binder.Functions() {
+				fmt.Fprintln(out, name)
+			}
+			return nil
+		case "-p":
+			printOnly = true
+			continue
+		case "-m", "-r":
+			if len(args) == 0 {
+				return fmt.Errorf("%s: option requires an argument. %s", flag, usage)
+			}
+			if flag == "-r" {
+				return binder.Bind(keymap, args[0], "")
+			}
+			keymap, args = args[0], args[1:]
+			continue
+		default:
+			return fmt.Errorf("%s: invalid option. %s", flag, usage)
+		}
+		break
+	}
+
+	switch len(args) {
+	case 0:
+		return printBindings(keymap, out)
+	case 2:
+		if printOnly {
+			return fmt.Errorf("%s", usage)
+		}
+		return binder.Bind(keymap, args[0], args[1])
+	default:
+		return fmt.Errorf("%s", usage)
+	}
+}
+
+// printBindings prints the bindings of a keymap as 'bind' commands.
+func printBindings(keymap string, out io.Writer) error {
+	bindings, err := binder.Bindings(keymap)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(bindings))
+	for k := range bindings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(out, "bind %s %s\n", utils.ShellQuote(k), bindings[k])
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("bind", &BindCommand{})
+}
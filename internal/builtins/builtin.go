@@ -5,8 +5,30 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+
+	"dush/internal/signals"
 )
 
+// stdinKey is the context key under which a command's standard input is stored.
+type stdinKey struct{}
+
+// WithStdin returns a copy of ctx carrying r as the standard input for a builtin.
+// Builtins run inside the shell process, so their input is passed through the
+// context rather than through Execute's arguments.
+func WithStdin(ctx context.Context, r io.Reader) context.Context {
+	return context.WithValue(ctx, stdinKey{}, r)
+}
+
+// Stdin returns the standard input a builtin should read from.
+// It defaults to os.Stdin when none was set with WithStdin.
+func Stdin(ctx context.Context) io.Reader {
+	if r, ok := ctx.Value(stdinKey{}).(io.Reader); ok && r != nil {
+		return r
+	}
+	return os.Stdin
+}
+
 // ListBuiltins returns a slice of strings containing the names of all registered built-in commands.
 func ListBuiltins() []string {
 	names := make([]string, 0, len(registeredCommands))
@@ -21,6 +43,14 @@ type Command interface {
 	Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error
 }
 
+// ExitStatus can be returned by a builtin to exit with a specific status
+// without printing an error message.
+type ExitStatus int
+
+func (e ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
 var registeredCommands = make(map[string]Command)
 
 // RegisterBuiltin registers a new built-in command.
@@ -28,21 +58,36 @@ func RegisterBuiltin(name string, cmd Command) {
 	registeredCommands[name] = cmd
 }
 
+// Lookup returns the built-in command registered under name.
+func Lookup(name string) (Command, bool) {
+	cmd, ok := registeredCommands[name]
+	return cmd, ok
+}
+
 // RunBuiltin checks if the given command name is a registered built-in command and executes it.
-// It returns true if a builtin was executed, false otherwise.
+// It returns the command's exit status and true if a builtin was executed, or false otherwise.
+// The status is 0 on success, 1 on error, 128 plus the signal number when the
+// command was interrupted (130 for Ctrl-C), or the value of an ExitStatus
+// returned by the builtin.
 // The context should be passed from the REPL to allow for cancellation.
-func RunBuiltin(ctx context.Context, cmdName string, args []string, out io.Writer, errOut io.Writer) bool {
-	if cmd, ok := registeredCommands[cmdName]; ok {
-		err := cmd.Execute(ctx, args, out, errOut)
-		if err != nil {
-			// Do not print error if context was cancelled, as it's an expected interruption
-			if errors.Is(err, context.Canceled) {
-				fmt.Fprintln(errOut, "Command interrupted.")
-			} else {
-				fmt.Fprintf(errOut, "%s: %v\n", cmdName, err)
-			}
-		}
-		return true
+func RunBuiltin(ctx context.Context, cmdName string, args []string, out io.Writer, errOut io.Writer) (int, bool) {
+	cmd, ok := registeredCommands[cmdName]
+	if !ok {
+		return 0, false
+	}
+	err := cmd.Execute(ctx, args, out, errOut)
+	if err == nil {
+		return 0, true
+	}
+	var status ExitStatus
+	if errors.As(err, &status) {
+		return int(status), true
+	}
+	// Do not print error if context was cancelled, as it's an expected interruption
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(errOut, "Command interrupted.")
+		return signals.ExitStatus(ctx), true
 	}
-	return false
+	fmt.Fprintf(errOut, "%s: %v\n", cmdName, err)
+	return 1, true
 }
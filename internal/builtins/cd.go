@@ -15,22 +15,34 @@ type CDCommand struct{}
 
 // Execute changes the shell's current working directory.
 func (c *CDCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
-	appInstance := app.GetApp() // Get the app singleton
+	env := app.CurrentEnv(ctx)
 
 	if len(args) == 0 {
-		// No argument given, change to home directory
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("cd: could not get home directory: %w", err)
+		// No argument given, change to $HOME or the user's home directory
+		homeDir, ok := env.GetVar("HOME")
+		if !ok || homeDir == "" {
+			var err error
+			if homeDir, err = os.UserHomeDir(); err != nil {
+				return fmt.Errorf("cd: could not get home directory: %w", err)
+			}
 		}
-		if err := appInstance.SetCurrentDir(homeDir); err != nil {
+		if err := env.SetCurrentDir(homeDir); err != nil {
 			return fmt.Errorf("cd: %w", err)
 		}
 		return nil
 	}
 
 	newPath := args[0]
-	currentCWD := appInstance.GetCurrentDir() // Use the app singleton
+	printDir := false
+	if newPath == "-" {
+		// `cd -` returns to the previous directory and prints it
+		newPath = env.GetPreviousDir()
+		if newPath == "" {
+			return fmt.Errorf("cd: previous directory not set")
+		}
+		printDir = true
+	}
+	currentCWD := env.GetCurrentDir()
 
 	// Resolve the new path relative to currentCWD
 	var absolutePath string
@@ -50,9 +62,12 @@ func (c *CDCommand) Execute(ctx context.Context, args []string, out io.Writer, e
 	}
 
 	// Set the new current working directory
-	if err := appInstance.SetCurrentDir(absolutePath); err != nil {
+	if err := env.SetCurrentDir(absolutePath); err != nil {
 		return fmt.Errorf("cd: %w", err)
 	}
+	if printDir {
+		fmt.Fprintln(out, absolutePath)
+	}
 	return nil
 }
 func init() {
@@ -0,0 +1,101 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/parser"
+	"dush/internal/utils"
+)
+
+// DeclareCommand implements the 'declare' builtin.
+type DeclareCommand struct{}
+
+// Execute prints function definitions with -f, or only their names with
+// -F. Otherwise it sets the named variables, exporting them with -x;
+// inside a function the variables are local to it, as with `local`.
+func (c *DeclareCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	var funcs, names, export bool
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'f':
+				funcs = true
+			case 'F':
+				names = true
+			case 'x':
+				export = true
+			default:
+				return fmt.Errorf("-%c: invalid option. Usage: declare [-fFx] [name[=value] ...]", flag)
+			}
+		}
+		args = args[1:]
+	}
+
+	env := app.CurrentEnv(ctx)
+	if funcs || names {
+		return printFunctions(args, names, out, errOut)
+	}
+
+	if len(args) == 0 {
+		for _, name := range env.VarNames() {
+			if v, ok := env.LookupVar(name); ok && (!export || v.Exported) {
+				fmt.Fprintf(out, "%s=%s\n", name, utils.ShellQuote(v.Value))
+			}
+		}
+		return nil
+	}
+
+	failed := false
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !parser.IsValidName(name) {
+			fmt.Fprintf(errOut, "declare: '%s': not a valid identifier\n", arg)
+			failed = true
+			continue
+		}
+		app.CurrentFrame(ctx).DeclareLocal(name)
+		if hasValue {
+			env.SetVar(name, value)
+		}
+		if export {
+			env.ExportVar(name)
+		}
+	}
+	if failed {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+// printFunctions prints the definitions of the named functions, or of all
+// functions when names is empty. With namesOnly just the names are printed.
+func printFunctions(names []string, namesOnly bool, out io.Writer, errOut io.Writer) error {
+	appInstance := app.GetApp()
+	if len(names) == 0 {
+		names = appInstance.FuncNames()
+	}
+	failed := false
+	for _, name := range names {
+		fn, ok := appInstance.GetFunc(name)
+		switch {
+		case !ok:
+			failed = true
+		case namesOnly:
+			fmt.Fprintf(out, "declare -f %s\n", name)
+		default:
+			fmt.Fprintln(out, fn.Source)
+		}
+	}
+	if failed {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("declare", &DeclareCommand{})
+}
@@ -0,0 +1,66 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"dush/internal/app"
+)
+
+// DisownCommand implements the 'disown' builtin.
+type DisownCommand struct{}
+
+// Execute removes jobs from the job table, so that they are neither
+// listed nor sent SIGHUP when the shell's terminal goes away. -a removes
+// every job and -r every running one; the current job is the default.
+func (c *DisownCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	all, running := false, false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'a':
+				all = true
+			case 'r':
+				running = true
+			default:
+				return fmt.Errorf("-%c: invalid option. Usage: disown [-ar] [jobspec ...]", flag)
+			}
+		}
+		args = args[1:]
+	}
+
+	appInstance := app.GetApp()
+	var list []*app.Job
+	switch {
+	case all || running:
+		for _, job := range appInstance.Jobs() {
+			if all || job.State() == app.JobRunning {
+				list = append(list, job)
+			}
+		}
+	case len(args) == 0:
+		job, err := appInstance.FindJob("%+")
+		if err != nil {
+			return err
+		}
+		list = append(list, job)
+	default:
+		for _, spec := range args {
+			job, err := appInstance.FindJob(spec)
+			if err != nil {
+				return err
+			}
+			list = append(list, job)
+		}
+	}
+	for _, job := range list {
+		appInstance.RemoveJob(job)
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("disown", &DisownCommand{})
+}
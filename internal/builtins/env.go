@@ -0,0 +1,85 @@
+package builtins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/utils"
+)
+
+// EnvCommand implements the `env` built-in command.
+type EnvCommand struct{}
+
+// Execute prints the environment passed to child processes. Leading
+// NAME=value arguments are added to it, and -i starts from an empty
+// environment. If a command follows, it is run with that environment
+// instead of printing it.
+func (c *EnvCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	shellEnv := app.CurrentEnv(ctx)
+
+	env := shellEnv.Environ()
+	if len(args) > 0 && (args[0] == "-i" || args[0] == "-") {
+		env = nil
+		args = args[1:]
+	}
+	for len(args) > 0 && strings.Contains(args[0], "=") {
+		env = setEnv(env, args[0])
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		for _, kv := range env {
+			fmt.Fprintln(out, kv)
+		}
+		return nil
+	}
+
+	pathList := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			pathList = kv[len("PATH="):]
+		}
+	}
+	path, err := utils.LookPath(args[0], pathList, shellEnv.GetCurrentDir())
+	if err != nil {
+		return fmt.Errorf("%s: command not found", args[0])
+	}
+
+	cmd := exec.CommandContext(ctx, path, args[1:]...)
+	cmd.Dir = shellEnv.GetCurrentDir()
+	cmd.Env = env
+	cmd.Stdin = Stdin(ctx)
+	cmd.Stdout = out
+	cmd.Stderr = errOut
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return ExitStatus(exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
+
+// setEnv replaces or appends a "NAME=value" pair in env.
+func setEnv(env []string, kv string) []string {
+	name, _, _ := strings.Cut(kv, "=")
+	for i, existing := range env {
+		if strings.HasPrefix(existing, name+"=") {
+			env[i] = kv
+			return env
+		}
+	}
+	return append(env, kv)
+}
+
+func init() {
+	RegisterBuiltin("env", &EnvCommand{})
+}
@@ -0,0 +1,62 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"dush/internal/app"
+)
+
+// exitKey is the context key under which the exit state is stored.
+type exitKey struct{}
+
+// ExitState records a request to exit the shell. Once Exiting is set the
+// evaluator stops running commands and the caller of the evaluator exits
+// with Status. Subshells like command substitutions get their own state.
+type ExitState struct {
+	Exiting bool
+	Status  int
+}
+
+// WithExit returns a copy of ctx carrying the exit state s.
+func WithExit(ctx context.Context, s *ExitState) context.Context {
+	return context.WithValue(ctx, exitKey{}, s)
+}
+
+// Exit returns the exit state stored in ctx, or nil.
+func Exit(ctx context.Context) *ExitState {
+	s, _ := ctx.Value(exitKey{}).(*ExitState)
+	return s
+}
+
+// ExitCommand implements the 'exit' builtin, also available as 'quit'.
+type ExitCommand struct{}
+
+// Execute exits the shell with status n, or with the status of the last
+// command when n is omitted.
+func (c *ExitCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	status := app.GetApp().LastStatus()
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(errOut, "exit: %s: numeric argument required\n", args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	if s := Exit(ctx); s != nil {
+		s.Exiting = true
+		s.Status = status
+	}
+	return ExitStatus(status)
+}
+
+func init() {
+	RegisterBuiltin("exit", &ExitCommand{})
+	RegisterBuiltin("quit", &ExitCommand{})
+}
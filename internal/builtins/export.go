@@ -0,0 +1,63 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/parser"
+	"dush/internal/utils"
+)
+
+// ExportCommand implements the `export` built-in command.
+type ExportCommand struct{}
+
+// Execute marks variables as exported so child processes receive them,
+// optionally assigning a value first. With -n it removes the export mark.
+func (c *ExportCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	env := app.CurrentEnv(ctx)
+
+	unexport := false
+	if len(args) > 0 && (args[0] == "-n" || args[0] == "-p") {
+		unexport = args[0] == "-n"
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		// List all exported variables
+		for _, name := range env.VarNames() {
+			if v, ok := env.LookupVar(name); ok && v.Exported {
+				fmt.Fprintf(out, "export %s=%s\n", name, utils.ShellQuote(v.Value))
+			}
+		}
+		return nil
+	}
+
+	failed := false
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !parser.IsValidName(name) {
+			fmt.Fprintf(errOut, "export: '%s': not a valid identifier\n", arg)
+			failed = true
+			continue
+		}
+		if hasValue {
+			env.SetVar(name, value)
+		}
+		if unexport {
+			env.UnexportVar(name)
+		} else {
+			env.ExportVar(name)
+		}
+	}
+	if failed {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("export", &ExportCommand{})
+}
@@ -0,0 +1,79 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"dush/internal/app"
+	"dush/internal/jobs"
+)
+
+// FgCommand implements the 'fg' builtin.
+type FgCommand struct{}
+
+// Execute moves a job to the foreground, continuing it if it is stopped,
+// and waits for it. The exit status is that of the job.
+func (c *FgCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	if !jobs.Enabled() {
+		return fmt.Errorf("no job control")
+	}
+	job, err := jobArg(args)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, job.Command)
+	if err := jobs.Continue(job, true); err != nil {
+		return err
+	}
+	return ExitStatus(jobs.Foreground(job, errOut))
+}
+
+// jobArg returns the job named by the only argument of fg,
+// or the current job without one.
+func jobArg(args []string) (*app.Job, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("too many arguments")
+	}
+	spec := "%+"
+	if len(args) == 1 {
+		spec = args[0]
+	}
+	return app.GetApp().FindJob(spec)
+}
+
+// BgCommand implements the 'bg' builtin.
+type BgCommand struct{}
+
+// Execute continues stopped jobs in the background, the current one by
+// default.
+func (c *BgCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	if !jobs.Enabled() {
+		return fmt.Errorf("no job control")
+	}
+	specs := args
+	if len(specs) == 0 {
+		specs = []string{"%+"}
+	}
+	appInstance := app.GetApp()
+	for _, spec := range specs {
+		job, err := appInstance.FindJob(spec)
+		if err != nil {
+			return err
+		}
+		if job.State() == app.JobDone {
+			return fmt.Errorf("%s: job has terminated", spec)
+		}
+		if err := jobs.Continue(job, false); err != nil {
+			return err
+		}
+		appInstance.MakeCurrentJob(job)
+		fmt.Fprintf(out, "[%d]+ %s &\n", job.ID(), job.Command)
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("fg", &FgCommand{})
+	RegisterBuiltin("bg", &BgCommand{})
+}
@@ -0,0 +1,69 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/jobs"
+)
+
+// JobsCommand implements the 'jobs' builtin.
+type JobsCommand struct{}
+
+// Execute lists the jobs in the job table, or the ones given as job specs.
+// -l adds process IDs and -p prints only the process IDs. Finished jobs
+// are removed from the table once listed.
+func (c *JobsCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	long, pidsOnly := false, false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'l':
+				long = true
+			case 'p':
+				pidsOnly = true
+			default:
+				return fmt.Errorf("-%c: invalid option. Usage: jobs [-lp] [jobspec ...]", flag)
+			}
+		}
+		args = args[1:]
+	}
+
+	appInstance := app.GetApp()
+	list := appInstance.Jobs()
+	if len(args) > 0 {
+		list = nil
+		for _, spec := range args {
+			job, err := appInstance.FindJob(spec)
+			if err != nil {
+				return err
+			}
+			list = append(list, job)
+		}
+	}
+
+	for _, job := range list {
+		if pidsOnly {
+			if pgid := job.Pgid(); pgid != 0 {
+				fmt.Fprintln(out, pgid)
+			} else if pids := job.Pids(); len(pids) > 0 {
+				fmt.Fprintln(out, pids[0])
+			}
+			continue
+		}
+		fmt.Fprintln(out, jobs.Format(job, long))
+	}
+	for _, job := range list {
+		if job.State() == app.JobDone {
+			appInstance.RemoveJob(job)
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("jobs", &JobsCommand{})
+}
@@ -0,0 +1,35 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"dush/internal/arith"
+)
+
+// LetCommand implements the 'let' builtin, which evaluates arithmetic expressions.
+type LetCommand struct{}
+
+// Execute evaluates each argument as an arithmetic expression. The exit
+// status is 0 if the last one is non-zero and 1 otherwise.
+func (c *LetCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expression expected")
+	}
+	var n int64
+	for _, expr := range args {
+		var err error
+		if n, err = arith.Eval(ctx, expr); err != nil {
+			return err
+		}
+	}
+	if n == 0 {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("let", &LetCommand{})
+}
@@ -0,0 +1,45 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/parser"
+)
+
+// LocalCommand implements the 'local' builtin.
+type LocalCommand struct{}
+
+// Execute declares variables local to the running function, optionally
+// assigning them. They are restored when the function returns.
+func (c *LocalCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	frame := app.CurrentFrame(ctx)
+	if !frame.InFunction() {
+		return fmt.Errorf("can only be used in a function")
+	}
+
+	failed := false
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !parser.IsValidName(name) {
+			fmt.Fprintf(errOut, "local: '%s': not a valid identifier\n", arg)
+			failed = true
+			continue
+		}
+		frame.DeclareLocal(name)
+		if hasValue {
+			app.CurrentEnv(ctx).SetVar(name, value)
+		}
+	}
+	if failed {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("local", &LocalCommand{})
+}
@@ -0,0 +1,91 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// loopKey is the context key under which the loop state is stored.
+type loopKey struct{}
+
+// LoopState tracks the loops enclosing a command and a pending break or
+// continue. The evaluator stops running commands while one is pending and
+// each loop it unwinds through decrements the count.
+type LoopState struct {
+	Depth    int // Number of enclosing loops
+	Break    int // Number of loops left to break out of
+	Continue int // Number of loops left to unwind; the last one continues
+}
+
+// Pending reports whether a break or continue has yet to be handled.
+func (s *LoopState) Pending() bool {
+	return s.Break > 0 || s.Continue > 0
+}
+
+// WithLoops returns a copy of ctx carrying the loop state s.
+func WithLoops(ctx context.Context, s *LoopState) context.Context {
+	return context.WithValue(ctx, loopKey{}, s)
+}
+
+// Loops returns the loop state stored in ctx, or nil.
+func Loops(ctx context.Context) *LoopState {
+	s, _ := ctx.Value(loopKey{}).(*LoopState)
+	return s
+}
+
+// BreakCommand implements the 'break' builtin.
+type BreakCommand struct{}
+
+// Execute exits from the innermost loop, or from n enclosing loops.
+func (c *BreakCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	loops, n, err := loopLevels(ctx, args)
+	if err != nil {
+		return err
+	}
+	loops.Break = n
+	return nil
+}
+
+// ContinueCommand implements the 'continue' builtin.
+type ContinueCommand struct{}
+
+// Execute resumes the next iteration of the innermost loop, or of the
+// n-th enclosing loop.
+func (c *ContinueCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	loops, n, err := loopLevels(ctx, args)
+	if err != nil {
+		return err
+	}
+	loops.Continue = n
+	return nil
+}
+
+// loopLevels returns the loop state and the number of levels given to
+// break or continue, capped at the number of enclosing loops.
+func loopLevels(ctx context.Context, args []string) (*LoopState, int, error) {
+	if len(args) > 1 {
+		return nil, 0, fmt.Errorf("too many arguments")
+	}
+	n := 1
+	if len(args) == 1 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			return nil, 0, fmt.Errorf("%s: numeric argument required", args[0])
+		}
+		if n < 1 {
+			return nil, 0, fmt.Errorf("%s: loop count out of range", args[0])
+		}
+	}
+	loops := Loops(ctx)
+	if loops == nil || loops.Depth == 0 {
+		return nil, 0, fmt.Errorf("only meaningful in a 'for', 'while', or 'until' loop")
+	}
+	return loops, min(n, loops.Depth), nil
+}
+
+func init() {
+	RegisterBuiltin("break", &BreakCommand{})
+	RegisterBuiltin("continue", &ContinueCommand{})
+}
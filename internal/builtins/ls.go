@@ -19,14 +19,12 @@ type LsCommand struct{}
 // lsOptions holds parsed options for the ls command.
 type lsOptions struct {
 	LongFormat bool
-	Path       string
+	Paths      []string
 }
 
 // parseLsArgs parses the arguments for the ls command.
 func parseLsArgs(args []string) (lsOptions, error) {
-	opts := lsOptions{
-		Path: ".", // Default path
-	}
+	opts := lsOptions{}
 
 	for _, arg := range args {
 		if strings.HasPrefix(arg, "-") {
@@ -41,11 +39,7 @@ func parseLsArgs(args []string) (lsOptions, error) {
 			}
 		} else {
 			// Assume it's a path if not a flag
-			if opts.Path != "." {
-				// Only one path argument supported for now
-				return opts, fmt.Errorf("ls: too many arguments (only one path supported)")
-			}
-			opts.Path = arg
+			opts.Paths = append(opts.Paths, arg)
 		}
 	}
 	return opts, nil
@@ -169,17 +163,64 @@ func (c *LsCommand) Execute(ctx context.Context, args []string, out io.Writer, e
 		return fmt.Errorf("ls: %w", err)
 	}
 
-	// Get the app singleton
-	appInstance := app.GetApp()
+	env := app.CurrentEnv(ctx)
 
 	// If no explicit path was provided, use the shell's current working directory
-	if opts.Path == "." {
-		opts.Path = appInstance.GetCurrentDir()
+	if len(opts.Paths) == 0 {
+		return listDirectory(ctx, env.GetCurrentDir(), opts, out, errOut)
+	}
+
+	// Files named on the command line are listed first, then each directory
+	var dirs []string
+	failed := false
+	for _, path := range opts.Paths {
+		fullPath := path
+		if !filepath.IsAbs(fullPath) {
+			fullPath = filepath.Join(env.GetCurrentDir(), path)
+		}
+		info, err := os.Lstat(fullPath)
+		if err != nil {
+			if pathErr, ok := err.(*os.PathError); ok {
+				err = pathErr.Err
+			}
+			fmt.Fprintf(errOut, "ls: cannot access '%s': %v\n", path, err)
+			failed = true
+			continue
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			continue
+		}
+		printEntry(fullPath, path, info, opts, out)
 	}
 
-	dirEntries, err := os.ReadDir(opts.Path)
+	for i, dir := range dirs {
+		if len(opts.Paths) > 1 {
+			if i > 0 || len(dirs) < len(opts.Paths) {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintf(out, "%s:\n", dir)
+		}
+		fullPath := dir
+		if !filepath.IsAbs(fullPath) {
+			fullPath = filepath.Join(env.GetCurrentDir(), dir)
+		}
+		if err := listDirectory(ctx, fullPath, opts, out, errOut); err != nil {
+			return err
+		}
+	}
+
+	if failed {
+		return ExitStatus(2)
+	}
+	return nil
+}
+
+// listDirectory prints the entries of the directory at path.
+func listDirectory(ctx context.Context, path string, opts lsOptions, out io.Writer, errOut io.Writer) error {
+	dirEntries, err := os.ReadDir(path)
 	if err != nil {
-		return fmt.Errorf("ls: cannot access '%s': %w", opts.Path, err)
+		return fmt.Errorf("ls: cannot access '%s': %w", path, err)
 	}
 
 	for _, entry := range dirEntries {
@@ -188,7 +229,7 @@ func (c *LsCommand) Execute(ctx context.Context, args []string, out io.Writer, e
 			return ctx.Err() // Command interrupted
 		default:
 			// Construct the full path to the current entry
-			fullEntryPath := filepath.Join(opts.Path, entry.Name())
+			fullEntryPath := filepath.Join(path, entry.Name())
 
 			info, err := entry.Info() // Get FileInfo for coloring and long listing
 			if err != nil {
@@ -196,17 +237,22 @@ func (c *LsCommand) Execute(ctx context.Context, args []string, out io.Writer, e
 				continue // Skip this entry
 			}
 
-			if opts.LongFormat {
-				fmt.Fprintln(out, formatLongListing(fullEntryPath, info))
-			} else {
-				fmt.Fprintln(out, colorizeFileName(fullEntryPath, info, entry.Name()))
-			}
+			printEntry(fullEntryPath, entry.Name(), info, opts, out)
 		}
 	}
 
 	return nil
 }
 
+// printEntry prints a single file, in long format if requested.
+func printEntry(fullPath string, name string, info fs.FileInfo, opts lsOptions, out io.Writer) {
+	if opts.LongFormat {
+		fmt.Fprintln(out, formatLongListing(fullPath, info))
+	} else {
+		fmt.Fprintln(out, colorizeFileName(fullPath, info, name))
+	}
+}
+
 func init() {
 	RegisterBuiltin("ls", &LsCommand{})
 }
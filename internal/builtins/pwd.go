@@ -17,8 +17,8 @@ func (c *PWDCommand) Execute(ctx context.Context, args []string, out io.Writer,
 		return fmt.Errorf("pwd: too many arguments")
 	}
 
-	appInstance := app.GetApp() // Get the app singleton
-	fmt.Fprintf(out, "%s\n", appInstance.GetCurrentDir())
+	env := app.CurrentEnv(ctx)
+	fmt.Fprintf(out, "%s\n", env.GetCurrentDir())
 	return nil
 }
 
@@ -0,0 +1,60 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"dush/internal/app"
+)
+
+// funcKey is the context key under which the function state is stored.
+type funcKey struct{}
+
+// FuncState tracks a running shell function. While Returning is set the
+// evaluator stops running the function's commands.
+type FuncState struct {
+	Depth     int // Nesting level of function calls
+	Returning bool
+}
+
+// WithFunc returns a copy of ctx carrying the function state s.
+func WithFunc(ctx context.Context, s *FuncState) context.Context {
+	return context.WithValue(ctx, funcKey{}, s)
+}
+
+// Func returns the state of the running function, or nil outside functions.
+func Func(ctx context.Context) *FuncState {
+	s, _ := ctx.Value(funcKey{}).(*FuncState)
+	return s
+}
+
+// ReturnCommand implements the 'return' builtin.
+type ReturnCommand struct{}
+
+// Execute returns from the running function with status n, or with the
+// status of the last command when n is omitted.
+func (c *ReturnCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	fn := Func(ctx)
+	if fn == nil {
+		return fmt.Errorf("can only return from a function")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	status := app.GetApp().LastStatus()
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("%s: numeric argument required", args[0])
+		}
+		status = n & 0xff
+	}
+	fn.Returning = true
+	return ExitStatus(status)
+}
+
+func init() {
+	RegisterBuiltin("return", &ReturnCommand{})
+}
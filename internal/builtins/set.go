@@ -0,0 +1,85 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"dush/internal/app"
+	"dush/internal/utils"
+)
+
+// SetCommand implements the `set` built-in command.
+type SetCommand struct{}
+
+// Execute enables (-o) or disables (+o) shell options, or lists them.
+// Single-letter flags like -e and +x are shorthands for options. Without
+// arguments it lists all shell variables. Arguments after "--" replace
+// the positional parameters.
+func (c *SetCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	appInstance := app.GetApp()
+
+	if len(args) == 0 {
+		env := app.CurrentEnv(ctx)
+		for _, name := range env.VarNames() {
+			if value, ok := env.GetVar(name); ok {
+				fmt.Fprintf(out, "%s=%s\n", name, utils.ShellQuote(value))
+			}
+		}
+		return nil
+	}
+
+	if len(args) == 1 && (args[0] == "-o" || args[0] == "+o") {
+		for _, name := range app.OptionNames() {
+			state := "off"
+			if appInstance.Option(name) {
+				state = "on"
+			}
+			fmt.Fprintf(out, "%-15s %s\n", name, state)
+		}
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		if flag == "--" {
+			app.CurrentFrame(ctx).SetParams(args[i+1:])
+			return nil
+		}
+		if flag != "-o" && flag != "+o" {
+			if err := setShortOptions(flag); err != nil {
+				return err
+			}
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("%s: option name required", flag)
+		}
+		i++
+		name := args[i]
+		if !app.IsOption(name) {
+			return fmt.Errorf("%s: invalid option name", name)
+		}
+		appInstance.SetOption(name, flag == "-o")
+	}
+	return nil
+}
+
+// setShortOptions applies a group of single-letter flags such as "-ex".
+func setShortOptions(flag string) error {
+	if len(flag) < 2 || (flag[0] != '-' && flag[0] != '+') {
+		return fmt.Errorf("invalid argument '%s'. Usage: set [-o | +o] <option> | set [-+efnxC] | set -- [arg ...]", flag)
+	}
+	for i := 1; i < len(flag); i++ {
+		name, ok := app.ShortOptions[flag[i]]
+		if !ok {
+			return fmt.Errorf("%c%c: invalid option", flag[0], flag[i])
+		}
+		app.GetApp().SetOption(name, flag[0] == '-')
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("set", &SetCommand{})
+}
@@ -0,0 +1,38 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"dush/internal/app"
+)
+
+// ShiftCommand implements the 'shift' builtin.
+type ShiftCommand struct{}
+
+// Execute drops the first n positional parameters, one by default.
+func (c *ShiftCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	n := 1
+	if len(args) == 1 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			return fmt.Errorf("%s: numeric argument required", args[0])
+		}
+	}
+	frame := app.CurrentFrame(ctx)
+	params := frame.Params()
+	if n > len(params) {
+		return ExitStatus(1)
+	}
+	frame.SetParams(params[n:])
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("shift", &ShiftCommand{})
+}
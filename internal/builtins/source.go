@@ -0,0 +1,83 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dush/internal/app"
+)
+
+// Sourcer runs the commands of a file in the current shell with args as
+// the positional parameters, if any, and returns their exit status.
+type Sourcer func(ctx context.Context, path string, args []string, out io.Writer, errOut io.Writer) (int, error)
+
+// sourcer is set by the evaluator, which this package cannot import.
+var sourcer Sourcer
+
+// SetSourcer registers the function 'source' uses to run files.
+func SetSourcer(fn Sourcer) {
+	sourcer = fn
+}
+
+// SourceCommand implements the 'source' builtin, also available as '.'.
+type SourceCommand struct{}
+
+// Execute reads and runs the commands of a file in the current shell, so
+// that variables, functions and the working directory it sets persist.
+// A name without a slash is looked up in PATH, then in the current
+// directory. The status is that of the last command run.
+func (c *SourceCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("filename argument required. Usage: source <file> [arg ...]")
+	}
+	if sourcer == nil {
+		return fmt.Errorf("not available")
+	}
+	path, err := findSourceFile(app.CurrentEnv(ctx), args[0])
+	if err != nil {
+		return err
+	}
+	status, err := sourcer(ctx, path, args[1:], out, errOut)
+	if err != nil {
+		return err
+	}
+	return ExitStatus(status)
+}
+
+// findSourceFile resolves the file argument of 'source' in env.
+func findSourceFile(env *app.Env, name string) (string, error) {
+	dir := env.GetCurrentDir()
+	if !strings.ContainsAny(name, `/\`) {
+		pathList, _ := env.GetVar("PATH")
+		for _, d := range filepath.SplitList(pathList) {
+			if d == "" || !filepath.IsAbs(d) {
+				continue
+			}
+			path := filepath.Join(d, name)
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				return path, nil
+			}
+		}
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: No such file or directory", name)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s: is a directory", name)
+	}
+	return path, nil
+}
+
+func init() {
+	RegisterBuiltin("source", &SourceCommand{})
+	RegisterBuiltin(".", &SourceCommand{})
+}
@@ -0,0 +1,133 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"dush/internal/app"
+	"dush/internal/signals"
+	"dush/internal/utils"
+)
+
+// TrapCommand implements the 'trap' builtin.
+type TrapCommand struct{}
+
+// Execute sets the command run when the shell receives a signal or on a
+// shell event: EXIT when the shell exits, ERR when a command fails, DEBUG
+// before each command and RETURN when a function or sourced file returns.
+//
+// `trap action condition...` sets the trap, ” ignores the condition and
+// '-' resets it, as does giving only conditions. Without arguments or with
+// -p the traps are printed as commands; -l lists the signal names.
+func (c *TrapCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	printOnly := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'l':
+				for _, sig := range signals.List() {
+					fmt.Fprintf(out, "%2d) SIG%s\n", int(sig), signals.Name(sig))
+				}
+				return nil
+			case 'p':
+				printOnly = true
+			default:
+				return fmt.Errorf("-%c: invalid option. Usage: trap [-lp] [[action] condition ...]", flag)
+			}
+		}
+		args = args[1:]
+	}
+
+	if printOnly || len(args) == 0 {
+		return printTraps(args, out)
+	}
+
+	action, reset := args[0], args[0] == "-"
+	conditions := args[1:]
+	if _, err := strconv.Atoi(action); err == nil || len(args) == 1 {
+		// Only conditions: each is reset
+		reset, conditions = true, args
+	}
+
+	appInstance := app.GetApp()
+	failed := false
+	for _, cond := range conditions {
+		name, sig, err := trapCondition(cond)
+		if err == nil && sig != 0 {
+			err = signals.Trappable(sig)
+		}
+		if err != nil {
+			fmt.Fprintf(errOut, "trap: %v\n", err)
+			failed = true
+			continue
+		}
+		if reset {
+			appInstance.ResetTrap(name)
+		} else {
+			appInstance.SetTrap(name, action)
+		}
+		if sig != 0 {
+			signals.Update(sig)
+		}
+	}
+	if failed {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+// trapCondition resolves a condition given to 'trap' to the name its trap
+// is stored under and, for a signal, the signal. EXIT is also 0.
+func trapCondition(cond string) (string, syscall.Signal, error) {
+	switch name := strings.ToUpper(cond); name {
+	case "0", app.TrapExit:
+		return app.TrapExit, 0, nil
+	case app.TrapErr, app.TrapDebug, app.TrapReturn:
+		return name, 0, nil
+	}
+	sig, ok := signals.Parse(cond)
+	if !ok {
+		return "", 0, fmt.Errorf("%s: invalid signal specification", cond)
+	}
+	return signals.Name(sig), sig, nil
+}
+
+// printTraps prints the traps for the given conditions, or all traps, as
+// commands that would set them again.
+func printTraps(conditions []string, out io.Writer) error {
+	appInstance := app.GetApp()
+	names := appInstance.TrapNames()
+	if len(conditions) > 0 {
+		names = nil
+		for _, cond := range conditions {
+			name, _, err := trapCondition(cond)
+			if err != nil {
+				return err
+			}
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		action, ok := appInstance.Trap(name)
+		if !ok {
+			continue
+		}
+		if _, isSignal := signals.Parse(name); isSignal {
+			name = "SIG" + name
+		}
+		fmt.Fprintf(out, "trap -- %s %s\n", utils.ShellQuote(action), name)
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("trap", &TrapCommand{})
+}
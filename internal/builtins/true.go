@@ -0,0 +1,27 @@
+package builtins
+
+import (
+	"context"
+	"io"
+)
+
+// TrueCommand implements the 'true' builtin, which always succeeds.
+type TrueCommand struct{}
+
+// Execute does nothing and returns a zero exit status.
+func (c *TrueCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	return nil
+}
+
+// FalseCommand implements the 'false' builtin, which always fails.
+type FalseCommand struct{}
+
+// Execute does nothing and returns an exit status of 1.
+func (c *FalseCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	return ExitStatus(1)
+}
+
+func init() {
+	RegisterBuiltin("true", &TrueCommand{})
+	RegisterBuiltin("false", &FalseCommand{})
+}
@@ -0,0 +1,86 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/config"
+	"dush/internal/parser"
+	"dush/internal/utils"
+)
+
+// TypeCommand implements the 'type' builtin.
+type TypeCommand struct{}
+
+// Execute describes how each name would be interpreted as a command, in
+// lookup order: alias, keyword, function, builtin, then file.
+// With -t only the kind is printed.
+func (c *TypeCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	kindOnly := false
+	if len(args) > 0 && args[0] == "-t" {
+		kindOnly = true
+		args = args[1:]
+	}
+
+	failed := false
+	for _, name := range args {
+		kind, desc := describeCommand(app.CurrentEnv(ctx), name)
+		switch {
+		case kind == "":
+			if !kindOnly {
+				fmt.Fprintf(errOut, "type: %s: not found\n", name)
+			}
+			failed = true
+		case kindOnly:
+			fmt.Fprintln(out, kind)
+		default:
+			fmt.Fprintln(out, desc)
+		}
+	}
+	if failed {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+// describeCommand returns the kind of command name refers to and a
+// description of it, or an empty kind if it is not found. Files are
+// looked up in the current directory and PATH of env.
+func describeCommand(env *app.Env, name string) (kind, desc string) {
+	if value, ok := config.GetConfig().Aliases[name]; ok {
+		return "alias", fmt.Sprintf("%s is aliased to '%s'", name, value)
+	}
+	if parser.IsReservedWord(name) {
+		return "keyword", fmt.Sprintf("%s is a shell keyword", name)
+	}
+	appInstance := app.GetApp()
+	if fn, ok := appInstance.GetFunc(name); ok {
+		return "function", fmt.Sprintf("%s is a function\n%s", name, fn.Source)
+	}
+	if _, ok := Lookup(name); ok {
+		return "builtin", fmt.Sprintf("%s is a shell builtin", name)
+	}
+
+	// Like the evaluator, look in the current directory before PATH
+	cwd := env.GetCurrentDir()
+	if !strings.ContainsAny(name, "/\\") {
+		local := filepath.Join(cwd, name)
+		if info, err := os.Stat(local); err == nil && !info.IsDir() {
+			return "file", fmt.Sprintf("%s is %s", name, local)
+		}
+	}
+	pathList, _ := env.GetVar("PATH")
+	if path, err := utils.LookPath(name, pathList, cwd); err == nil {
+		return "file", fmt.Sprintf("%s is %s", name, path)
+	}
+	return "", ""
+}
+
+func init() {
+	RegisterBuiltin("type", &TypeCommand{})
+}
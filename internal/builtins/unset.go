@@ -0,0 +1,46 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"dush/internal/app"
+	"dush/internal/parser"
+)
+
+// UnsetCommand implements the `unset` built-in command.
+type UnsetCommand struct{}
+
+// Execute removes the named shell variables, or functions with -f.
+func (c *UnsetCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	appInstance := app.GetApp()
+
+	funcs := false
+	if len(args) > 0 && (args[0] == "-v" || args[0] == "-f") {
+		funcs = args[0] == "-f"
+		args = args[1:]
+	}
+
+	failed := false
+	for _, name := range args {
+		if funcs {
+			appInstance.UnsetFunc(name)
+			continue
+		}
+		if !parser.IsValidName(name) {
+			fmt.Fprintf(errOut, "unset: '%s': not a valid identifier\n", name)
+			failed = true
+			continue
+		}
+		app.CurrentEnv(ctx).UnsetVar(name)
+	}
+	if failed {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+func init() {
+	RegisterBuiltin("unset", &UnsetCommand{})
+}
@@ -0,0 +1,83 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/jobs"
+)
+
+// WaitCommand implements the 'wait' builtin.
+type WaitCommand struct{}
+
+// Execute waits for the given jobs or process IDs to finish and returns
+// the status of the last one. Without arguments it waits for every job
+// and returns 0. Jobs waited for are removed from the job table.
+func (c *WaitCommand) Execute(ctx context.Context, args []string, out io.Writer, errOut io.Writer) error {
+	appInstance := app.GetApp()
+	if len(args) == 0 {
+		for _, job := range appInstance.Jobs() {
+			if _, err := waitJob(ctx, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	status := 0
+	for _, arg := range args {
+		job, err := waitTarget(arg)
+		if err != nil {
+			fmt.Fprintf(errOut, "wait: %v\n", err)
+			status = 127
+			continue
+		}
+		if status, err = waitJob(ctx, job); err != nil {
+			return err
+		}
+	}
+	return ExitStatus(status)
+}
+
+// waitTarget finds the job for a job spec or for a process ID.
+func waitTarget(arg string) (*app.Job, error) {
+	appInstance := app.GetApp()
+	if strings.HasPrefix(arg, "%") {
+		return appInstance.FindJob(arg)
+	}
+	pid, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: not a pid or valid job spec", arg)
+	}
+	for _, job := range appInstance.Jobs() {
+		for _, p := range job.Pids() {
+			if p == pid {
+				return job, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("pid %d is not a child of this shell", pid)
+}
+
+// waitJob waits until job finishes, removes it from the job table and
+// returns its status. A stopped job is not waited for.
+func waitJob(ctx context.Context, job *app.Job) (int, error) {
+	if job.State() == app.JobStopped {
+		return jobs.StoppedStatus, nil
+	}
+	select {
+	case <-job.Done():
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	app.GetApp().RemoveJob(job)
+	return job.Status(), nil
+}
+
+func init() {
+	RegisterBuiltin("wait", &WaitCommand{})
+}
@@ -22,7 +22,18 @@ type Config struct {
 	UserName     string `piml:"user_name"`
 	PromptPrefix string `piml:"prompt_prefix"`
 	PromptSuffix string `piml:"prompt_suffix"`
-	Aliases      map[string]string
+	// HistorySearch is "fuzzy" for Ctrl-R to rank fuzzy matches by how
+	// recent and frequent they are, rather than list exact substrings
+	HistorySearch string `piml:"history_search"`
+	// EditMode is "vi" to edit command lines with Vi keys, or "emacs"
+	EditMode string `piml:"edit_mode"`
+	// KeyBindings binds keys such as "C-x" or "M-f" to editing functions,
+	// in the keymap of EditMode; see the 'bind' builtin
+	KeyBindings map[string]string `piml:"key_bindings"`
+	// Theme colors the classes of syntax highlighting, such as "command"
+	// or "string", with names such as "bold green" or "none"
+	Theme   map[string]string `piml:"theme"`
+	Aliases map[string]string
 }
 
 // loadConfig reads configuration from the specified PIML file.
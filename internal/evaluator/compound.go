@@ -0,0 +1,245 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+
+	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/parser"
+)
+
+// allParams is the word "$@", which a for loop without 'in' iterates over.
+var allParams = &parser.Word{Parts: []parser.WordPart{
+	&parser.DblQuoted{Parts: []parser.WordPart{&parser.ParamExp{Short: true, Param: "@"}}},
+}}
+
+// stopped reports whether the commands of the current list should stop
+// running, because of an interrupt, a pending break or continue, a return
+// or an exit.
+func stopped(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	if exit := builtins.Exit(ctx); exit != nil && exit.Exiting {
+		return true
+	}
+	if fn := builtins.Func(ctx); fn != nil && fn.Returning {
+		return true
+	}
+	loops := builtins.Loops(ctx)
+	return loops != nil && loops.Pending()
+}
+
+// runRedirected applies the redirections of a compound command and runs it
+// with the resulting streams.
+func runRedirected(ctx context.Context, redirs []*parser.Redirect, std stdio, run func(std stdio) int) int {
+	if len(redirs) == 0 {
+		return run(std)
+	}
+	exp := &expander{ctx: ctx, std: std}
+	rstd, closeFiles, err := applyRedirects(exp, redirs, std)
+	defer closeFiles()
+	if err != nil {
+		fmt.Fprintf(std.err, "dush: %v\n", err)
+		return 1
+	}
+	return run(rstd)
+}
+
+// runBlock runs a { list; } group in the current shell.
+func runBlock(ctx context.Context, b *parser.Block, std stdio) int {
+	return runRedirected(ctx, b.Redirs, std, func(std stdio) int {
+		return runStmts(ctx, b.Stmts, std)
+	})
+}
+
+// runIf runs the first branch whose condition succeeds. The status is that
+// of the branch, or 0 if none ran.
+func runIf(ctx context.Context, ic *parser.IfClause, std stdio) int {
+	return runRedirected(ctx, ic.Redirs, std, func(std stdio) int {
+		for c := ic; c != nil; c = c.Else {
+			if len(c.Cond) > 0 {
+				status := runStmts(withoutErrexit(ctx), c.Cond, std)
+				if stopped(ctx) {
+					return status
+				}
+				if status != 0 {
+					continue
+				}
+			}
+			return runStmts(ctx, c.Then, std)
+		}
+		return 0
+	})
+}
+
+// enterLoop registers a loop in the loop state of ctx and returns the
+// state with a function to call when the loop ends.
+func enterLoop(ctx context.Context) (*builtins.LoopState, func()) {
+	loops := builtins.Loops(ctx)
+	loops.Depth++
+	return loops, func() { loops.Depth-- }
+}
+
+// loopDone handles a pending break or continue after running part of a
+// loop, and reports whether the loop must end.
+func loopDone(ctx context.Context, loops *builtins.LoopState) bool {
+	switch {
+	case loops.Break > 0:
+		loops.Break--
+		return true
+	case loops.Continue > 1:
+		// An outer loop continues
+		loops.Continue--
+		return true
+	case loops.Continue == 1:
+		loops.Continue = 0
+	}
+	return stopped(ctx)
+}
+
+// runWhile runs a while or until loop. The status is that of the last
+// command of the body, or 0 if the body never ran.
+func runWhile(ctx context.Context, wc *parser.WhileClause, std stdio) int {
+	return runRedirected(ctx, wc.Redirs, std, func(std stdio) int {
+		loops, leave := enterLoop(ctx)
+		defer leave()
+		status := 0
+		for {
+			cond := runStmts(withoutErrexit(ctx), wc.Cond, std)
+			if stopped(ctx) {
+				if loopDone(ctx, loops) {
+					return status
+				}
+				continue
+			}
+			if (cond == 0) == wc.Until {
+				return status
+			}
+			status = runStmts(ctx, wc.Do, std)
+			if loopDone(ctx, loops) {
+				return status
+			}
+		}
+	})
+}
+
+// runFor runs a for loop, assigning each expanded item to the loop variable.
+func runFor(ctx context.Context, fc *parser.ForClause, std stdio) int {
+	return runRedirected(ctx, fc.Redirs, std, func(std stdio) int {
+		words := fc.Items
+		if !fc.HasIn {
+			words = []*parser.Word{allParams}
+		}
+		exp := &expander{ctx: ctx, std: std}
+		items, err := exp.expandWords(words)
+		if err != nil {
+			fmt.Fprintf(std.err, "dush: %v\n", err)
+			return 1
+		}
+
+		loops, leave := enterLoop(ctx)
+		defer leave()
+		status := 0
+		for _, item := range items {
+			app.CurrentEnv(ctx).SetVar(fc.Name, item)
+			status = runStmts(ctx, fc.Do, std)
+			if loopDone(ctx, loops) {
+				break
+			}
+		}
+		return status
+	})
+}
+
+// runArithmFor runs a C-style for loop. An empty condition is always true.
+func runArithmFor(ctx context.Context, fc *parser.ArithmForClause, std stdio) int {
+	return runRedirected(ctx, fc.Redirs, std, func(std stdio) int {
+		exp := &expander{ctx: ctx, std: std}
+		if _, err := exp.expandArithm(fc.Init); err != nil {
+			fmt.Fprintf(std.err, "dush: %v\n", err)
+			return 1
+		}
+
+		loops, leave := enterLoop(ctx)
+		defer leave()
+		status := 0
+		for {
+			if fc.Cond != nil {
+				n, err := exp.expandArithm(fc.Cond)
+				if err != nil {
+					fmt.Fprintf(std.err, "dush: %v\n", err)
+					return 1
+				}
+				if n == 0 {
+					return status
+				}
+			}
+			status = runStmts(ctx, fc.Do, std)
+			if loopDone(ctx, loops) {
+				return status
+			}
+			if _, err := exp.expandArithm(fc.Post); err != nil {
+				fmt.Fprintf(std.err, "dush: %v\n", err)
+				return 1
+			}
+		}
+	})
+}
+
+// runCase runs the commands of the first item with a pattern matching the
+// word. ";&" falls through to the next item's commands and ";;&" goes on
+// testing the following patterns.
+func runCase(ctx context.Context, cc *parser.CaseClause, std stdio) int {
+	return runRedirected(ctx, cc.Redirs, std, func(std stdio) int {
+		exp := &expander{ctx: ctx, std: std}
+		word, err := exp.expandWord(cc.Word)
+		if err != nil {
+			fmt.Fprintf(std.err, "dush: %v\n", err)
+			return 1
+		}
+
+		status := 0
+		fallthru := false
+		for _, item := range cc.Items {
+			if !fallthru {
+				matched, err := caseMatches(exp, item, word)
+				if err != nil {
+					fmt.Fprintf(std.err, "dush: %v\n", err)
+					return 1
+				}
+				if !matched {
+					continue
+				}
+			}
+			status = runStmts(ctx, item.Stmts, std)
+			if stopped(ctx) {
+				return status
+			}
+			switch item.Op {
+			case parser.CaseBreak:
+				return status
+			case parser.CaseFallthrough:
+				fallthru = true
+			case parser.CaseResume:
+				fallthru = false
+			}
+		}
+		return status
+	})
+}
+
+// caseMatches reports whether any pattern of item matches word.
+func caseMatches(exp *expander, item *parser.CaseItem, word string) (bool, error) {
+	for _, w := range item.Patterns {
+		pattern, err := exp.expandPattern(w)
+		if err != nil {
+			return false, err
+		}
+		if matchPattern(pattern, word) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
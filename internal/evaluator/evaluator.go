@@ -3,19 +3,415 @@ package evaluator
 import (
 	"context"
 	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/config"
+	"dush/internal/jobs"
+	"dush/internal/parser"
+	"dush/internal/signals"
+	"dush/internal/utils"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 )
 
-// ExecuteExternal runs an external command.
-func ExecuteExternal(ctx context.Context, cmdName string, args []string, out io.Writer, errOut io.Writer) error {
+// stdio holds the standard streams a command runs with.
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+}
+
+// Run executes every statement of a parsed command line in order and
+// returns the exit status of the last one.
+//
+// If ctx carries no exit state, 'exit' only stops the statements of file.
+func Run(ctx context.Context, file *parser.File, out io.Writer, errOut io.Writer) int {
+	ctx = builtins.WithLoops(ctx, &builtins.LoopState{})
+	if builtins.Exit(ctx) == nil {
+		ctx = builtins.WithExit(ctx, &builtins.ExitState{})
+	}
+	runStmts(ctx, file.Stmts, stdio{in: os.Stdin, out: out, err: errOut})
+	return app.GetApp().LastStatus()
+}
+
+// runStmts executes statements in order and returns the last exit status,
+// or 0 if none ran. It stops early on an interrupt, break or continue.
+// The traps of signals received meanwhile run after each statement.
+func runStmts(ctx context.Context, stmts []*parser.Stmt, std stdio) int {
+	status := 0
+	for _, stmt := range stmts {
+		if stopped(ctx) {
+			break
+		}
+		status = runStmt(ctx, stmt, std)
+		runPendingTraps(ctx, std)
+	}
+	return status
+}
+
+// subshellContext returns a copy of ctx for commands that behave like a
+// subshell, such as pipeline stages: a break inside them cannot leave the
+// loops of the shell, an exit only ends the subshell and traps do not run.
+// The subshell works on copies of the variables, working directory and
+// positional parameters, so what it changes does not reach the shell.
+func subshellContext(ctx context.Context) context.Context {
+	env := app.CurrentEnv(ctx).Clone()
+	frame := app.CurrentFrame(ctx).Clone(env)
+	ctx = app.WithFrame(app.WithEnv(ctx, env), frame)
+	ctx = builtins.WithExit(withoutTraps(ctx), &builtins.ExitState{})
+	loops := &builtins.LoopState{}
+	if outer := builtins.Loops(ctx); outer != nil {
+		loops.Depth = outer.Depth
+	}
+	ctx = builtins.WithLoops(ctx, loops)
+	if outer := builtins.Func(ctx); outer != nil {
+		ctx = builtins.WithFunc(ctx, &builtins.FuncState{Depth: outer.Depth})
+	}
+	return ctx
+}
+
+// runStmt executes a single statement, records its exit status as $? and returns it.
+// With job control, a statement run by the shell itself rather than by a
+// job becomes a foreground job that can be stopped. The DEBUG trap runs
+// before the statement.
+func runStmt(ctx context.Context, stmt *parser.Stmt, std stdio) int {
+	runTrap(ctx, app.TrapDebug, std)
+	if stopped(ctx) {
+		return app.GetApp().LastStatus()
+	}
+	var status int
+	switch {
+	case stmt.Background:
+		status = runBackground(ctx, stmt, std)
+	case jobs.Enabled() && jobs.FromContext(ctx) == nil:
+		status = runForeground(ctx, stmt, std)
+	default:
+		status = runCommand(ctx, stmt.Cmd, std)
+	}
+	app.GetApp().SetLastStatus(status)
+	return status
+}
+
+// errexitKey is the context key marking commands whose failure does not
+// make the shell exit under 'set -e'.
+type errexitKey struct{}
+
+// withoutErrexit returns a copy of ctx in which failing commands do not
+// trigger errexit, as in the condition of an if or the left side of '&&'.
+func withoutErrexit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, errexitKey{}, true)
+}
+
+// checkFailure handles cmd failing with status outside of a tested
+// context. The status of '&&' and '||' lists and of negated pipelines is
+// never checked itself. The ERR trap runs for the simple commands and
+// pipelines of the shell, outside of functions, and with the errexit
+// option set the shell exits.
+func checkFailure(ctx context.Context, cmd parser.Command, status int, std stdio) {
+	if status == 0 {
+		return
+	}
+	if ignored, _ := ctx.Value(errexitKey{}).(bool); ignored {
+		return
+	}
+	switch cmd := cmd.(type) {
+	case *parser.BinaryCmd:
+		return
+	case *parser.Pipeline:
+		if cmd.Negated {
+			return
+		}
+	}
+	switch cmd.(type) {
+	case *parser.CallExpr, *parser.Pipeline, *parser.ArithmCmd:
+		if fn := builtins.Func(ctx); fn == nil || fn.Depth == 0 {
+			app.GetApp().SetLastStatus(status)
+			runTrap(ctx, app.TrapErr, std)
+		}
+	}
+	if !app.GetApp().Option(app.OptErrexit) {
+		return
+	}
+	if s := builtins.Exit(ctx); s != nil && !s.Exiting {
+		s.Exiting = true
+		s.Status = status
+	}
+}
+
+// runCommand executes a command node and returns its exit status.
+func runCommand(ctx context.Context, cmd parser.Command, std stdio) int {
+	switch cmd.(type) {
+	case *parser.CallExpr, *parser.Pipeline, *parser.BinaryCmd, *parser.ArithmCmd:
+		// These mark the job launched themselves once they run a program
+	default:
+		markLaunched(ctx)
+	}
+	status := dispatch(ctx, cmd, std)
+	checkFailure(ctx, cmd, status, std)
+	return status
+}
+
+// dispatch runs a command node with the function for its type.
+func dispatch(ctx context.Context, cmd parser.Command, std stdio) int {
+	switch cmd := cmd.(type) {
+	case *parser.CallExpr:
+		return runCall(ctx, cmd, std)
+	case *parser.Pipeline:
+		return runPipeline(ctx, cmd, std)
+	case *parser.BinaryCmd:
+		return runBinary(ctx, cmd, std)
+	case *parser.ArithmCmd:
+		return runArithmCmd(ctx, cmd, std)
+	case *parser.IfClause:
+		return runIf(ctx, cmd, std)
+	case *parser.WhileClause:
+		return runWhile(ctx, cmd, std)
+	case *parser.ForClause:
+		return runFor(ctx, cmd, std)
+	case *parser.ArithmForClause:
+		return runArithmFor(ctx, cmd, std)
+	case *parser.CaseClause:
+		return runCase(ctx, cmd, std)
+	case *parser.Block:
+		return runBlock(ctx, cmd, std)
+	case *parser.FuncDecl:
+		app.GetApp().SetFunc(cmd.Name, &app.Function{Body: cmd.Body, Source: cmd.Source})
+		return 0
+	}
+	return 1
+}
+
+// runArithmCmd evaluates a ((...)) command. The exit status is 0 if the
+// expression is non-zero and 1 if it is zero or invalid.
+func runArithmCmd(ctx context.Context, a *parser.ArithmCmd, std stdio) int {
+	exp := &expander{ctx: ctx, std: std}
+	n, err := exp.expandArithm(a.X)
+	if err != nil {
+		fmt.Fprintf(std.err, "dush: %v\n", err)
+		return 1
+	}
+	if n == 0 {
+		return 1
+	}
+	return 0
+}
+
+// runBinary runs the left side of '&&' or '||' and, depending on its exit
+// status, the right side. $? is updated after each side runs.
+func runBinary(ctx context.Context, b *parser.BinaryCmd, std stdio) int {
+	status := runCommand(withoutErrexit(ctx), b.X, std)
+	app.GetApp().SetLastStatus(status)
+	if stopped(ctx) {
+		return status
+	}
+	if (b.Op == parser.AndIf) == (status == 0) {
+		return runCommand(ctx, b.Y, std)
+	}
+	return status
+}
+
+// runCall executes a simple command: a function, a builtin or an external
+// program, looked up in that order after aliases have been expanded.
+func runCall(ctx context.Context, call *parser.CallExpr, std stdio) int {
 	appInstance := app.GetApp()
-	currentDir := appInstance.GetCurrentDir()
+	env := app.CurrentEnv(ctx)
+	exp := &expander{ctx: ctx, std: std}
+	words := call.Args
+	if len(words) > 0 {
+		words = expandAlias(words)
+	}
+	var args []string
+	var err error
+	if len(words) > 0 && isDeclBuiltin(words[0].Lit()) {
+		args, err = exp.expandDeclArgs(words)
+	} else {
+		args, err = exp.expandWords(words)
+	}
+	if err != nil {
+		fmt.Fprintf(std.err, "dush: %v\n", err)
+		return 1
+	}
+
+	if appInstance.Option(app.OptXtrace) {
+		trace(exp, call.Assigns, args, std)
+	}
 
-	fullPath := cmdName
+	std, closeFiles, err := applyRedirects(exp, call.Redirs, std)
+	defer closeFiles()
+	if err != nil {
+		fmt.Fprintf(std.err, "dush: %v\n", err)
+		return 1
+	}
+	if len(args) == 0 {
+		// Assignments without a command set shell variables
+		for _, as := range call.Assigns {
+			value, err := exp.assignValue(as)
+			if err != nil {
+				fmt.Fprintf(std.err, "dush: %v\n", err)
+				return 1
+			}
+			env.SetVar(as.Name, value)
+		}
+		// The status is that of the last command substitution, if any ran
+		if exp.substRan {
+			return appInstance.LastStatus()
+		}
+		return 0
+	}
+	cmdName, args := args[0], args[1:]
+
+	fn, isFunc := appInstance.GetFunc(cmdName)
+	if _, isBuiltin := builtins.Lookup(cmdName); isFunc || isBuiltin {
+		// Prefix assignments are exported only for the duration of the
+		// function or builtin
+		for _, as := range call.Assigns {
+			value, err := exp.assignValue(as)
+			if err != nil {
+				fmt.Fprintf(std.err, "dush: %v\n", err)
+				return 1
+			}
+			saved, wasSet := env.LookupVar(as.Name)
+			env.SetVar(as.Name, value)
+			env.ExportVar(as.Name)
+			defer env.RestoreVar(as.Name, saved, wasSet)
+		}
+		markLaunched(ctx)
+		if isFunc {
+			return callFunction(ctx, cmdName, fn, args, std)
+		}
+		status, _ := builtins.RunBuiltin(builtins.WithStdin(ctx, std.in), cmdName, args, std.out, std.err)
+		return status
+	}
+
+	environ := env.Environ()
+	for _, as := range call.Assigns {
+		value, err := exp.assignValue(as)
+		if err != nil {
+			fmt.Fprintf(std.err, "dush: %v\n", err)
+			return 1
+		}
+		environ = append(environ, as.Name+"="+value)
+	}
+	err = ExecuteExternal(ctx, cmdName, args, environ, std.in, std.out, std.err)
+	if err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			fmt.Fprintf(std.err, "Command not found: %s\n", cmdName)
+			return 127
+		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			// Other errors (like execution failure)
+			fmt.Fprintf(std.err, "Error executing %s: %v\n", cmdName, err)
+			return 126
+		}
+	}
+	return exitStatus(ctx, err)
+}
+
+// trace prints a simple command to standard error for 'set -x', after
+// expansion and preceded by the expanded value of PS4.
+func trace(exp *expander, assigns []*parser.Assign, args []string, std stdio) {
+	prefix := "+ "
+	if ps4, ok := app.CurrentEnv(exp.ctx).GetVar("PS4"); ok {
+		prefix = ps4
+	}
+	var words []string
+	for _, as := range assigns {
+		value, err := exp.assignValue(as)
+		if err != nil {
+			return
+		}
+		words = append(words, as.Name+"="+utils.ShellQuote(value))
+	}
+	for _, arg := range args {
+		words = append(words, utils.ShellQuote(arg))
+	}
+	fmt.Fprintf(std.err, "%s%s\n", prefix, strings.Join(words, " "))
+}
+
+// assignValue expands the value of an assignment, appending it to the
+// variable's current value for NAME+=value.
+func (e *expander) assignValue(as *parser.Assign) (string, error) {
+	var value string
+	if as.Value != nil {
+		parts, err := e.expandWordParts(as.Value, true)
+		if err != nil {
+			return "", err
+		}
+		value = joinParts(parts)
+	}
+	if as.Append {
+		current, _ := app.CurrentEnv(e.ctx).GetVar(as.Name)
+		value = current + value
+	}
+	return value, nil
+}
+
+// exitStatus converts the error returned by running an external command
+// into a shell exit status. A process killed by a signal reports 128 plus
+// the signal number, as does one whose context was cancelled by Ctrl-C.
+// When the signal was SIGINT or SIGQUIT, the rest of the foreground
+// command is interrupted too.
+func exitStatus(ctx context.Context, err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			sig := ws.Signal()
+			if sig == syscall.SIGINT || sig == syscall.SIGQUIT {
+				if job := jobs.FromContext(ctx); job == nil || job.Foreground() {
+					signals.Interrupted(sig)
+				}
+			}
+			return 128 + int(sig)
+		}
+		if exitErr.ExitCode() >= 0 {
+			return exitErr.ExitCode()
+		}
+	}
+	if ctx.Err() != nil {
+		return signals.ExitStatus(ctx)
+	}
+	return 1
+}
+
+// expandAlias replaces the command word with the words of its alias, if any.
+// An alias is expanded only once, so `alias ls='ls -l'` does not recurse.
+func expandAlias(words []*parser.Word) []*parser.Word {
+	name := words[0].Lit()
+	if name == "" {
+		return words
+	}
+	value, ok := config.GetConfig().Aliases[name]
+	if !ok {
+		return words
+	}
+	file, err := parser.Parse(value)
+	if err != nil || len(file.Stmts) != 1 {
+		return words
+	}
+	call, ok := file.Stmts[0].Cmd.(*parser.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return words
+	}
+	// Append original args to expanded alias args
+	return append(call.Args, words[1:]...)
+}
+
+// ExecuteExternal runs an external command with the given environment.
+// The command is looked up using the shell's PATH variable. If ctx carries
+// a job, the process becomes part of it.
+func ExecuteExternal(ctx context.Context, cmdName string, args []string, env []string, in io.Reader, out io.Writer, errOut io.Writer) error {
+	shellEnv := app.CurrentEnv(ctx)
+	currentDir := shellEnv.GetCurrentDir()
+
+	fullPath := ""
 	// If the command doesn't have a path separator, check the current directory
 	if !strings.ContainsAny(cmdName, "/\\") {
 		localPath := filepath.Join(currentDir, cmdName)
@@ -23,12 +419,43 @@ func ExecuteExternal(ctx context.Context, cmdName string, args []string, out io.
 			fullPath = localPath
 		}
 	}
+	if fullPath == "" {
+		pathList, _ := shellEnv.GetVar("PATH")
+		var err error
+		if fullPath, err = utils.LookPath(cmdName, pathList, currentDir); err != nil {
+			return err
+		}
+	}
 
 	cmd := exec.CommandContext(ctx, fullPath, args...)
+	cmd.Args[0] = cmdName
 	cmd.Dir = currentDir
+	cmd.Env = env
 	cmd.Stdout = out
 	cmd.Stderr = errOut
-	cmd.Stdin = os.Stdin
+	cmd.Stdin = in
+
+	// SIGINT and SIGQUIT reach the process from the terminal, or from the
+	// shell if it was sent them by other means; other cancellations kill it.
+	cmd.Cancel = func() error {
+		var intr *signals.Interrupt
+		if errors.As(context.Cause(ctx), &intr) {
+			if intr.Forward {
+				return cmd.Process.Signal(intr.Signal)
+			}
+			return nil
+		}
+		return cmd.Process.Kill()
+	}
 
-	return cmd.Run()
+	job := jobs.FromContext(ctx)
+	if err := jobs.Start(cmd, job); err != nil {
+		return err
+	}
+	err := jobs.Wait(cmd, job)
+	if err != nil && cmd.ProcessState != nil && cmd.ProcessState.Success() {
+		// The process handled the interrupt and exited normally
+		return nil
+	}
+	return err
 }
@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"dush/internal/config"
+	"dush/internal/parser"
+)
+
+// TestMain loads an empty configuration, which alias expansion needs.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "dush-test")
+	if err != nil {
+		panic(err)
+	}
+	configPath := filepath.Join(dir, "config.piml")
+	if err := os.WriteFile(configPath, nil, 0o644); err != nil {
+		panic(err)
+	}
+	config.InitConfig(configPath, filepath.Join(dir, "aliases.piml"))
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// syncBuffer is a bytes.Buffer that the stages of a pipeline can write to
+// at the same time.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// run parses and runs src, returning what it writes to standard output.
+func run(t *testing.T, src string) string {
+	t.Helper()
+	f, err := parser.Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	var out, errOut syncBuffer
+	Run(context.Background(), f, &out, &errOut)
+	if errOut.String() != "" {
+		t.Errorf("%q: unexpected error output %q", src, errOut.String())
+	}
+	return out.String()
+}
+
+func TestSubshellState(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`cd /; x=$(cd /tmp; pwd); echo $x $PWD`, "/tmp /\n"},
+		{`unset z; y=$(z=1; echo $z); echo "[$z] $y"`, "[] 1\n"},
+		{`set -- a b; n=$(shift; echo $#); echo $n $#`, "1 2\n"},
+		{`f() { local l=1; echo $(l=2; echo $l) $l; }; f`, "2 1\n"},
+		{`cd /; cd /tmp | cat; pwd`, "/\n"},
+		{`x=1; x=2 | cat; echo $x`, "1\n"},
+		{`f() { local x=$1; echo $1 $x; }; f a | f b`, "b b\n"},
+		{`set -- a b; set -- c | cat; echo "$@"`, "a b\n"},
+	}
+	for _, tt := range tests {
+		if got := run(t, tt.src); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
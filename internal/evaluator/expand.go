@@ -0,0 +1,612 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"dush/internal/app"
+	"dush/internal/arith"
+	"dush/internal/builtins"
+	"dush/internal/parser"
+)
+
+// defaultIFS is used for field splitting when IFS is unset.
+const defaultIFS = " \t\n"
+
+// expander performs the expansions of a single command. Command
+// substitutions run with its context and streams.
+type expander struct {
+	ctx      context.Context
+	std      stdio
+	substRan bool // A command substitution ran; its status is in $?
+}
+
+// fieldPart is a piece of an expanded word. Quoted pieces are protected
+// from field splitting and pattern matching.
+type fieldPart struct {
+	val    string
+	quoted bool
+	split  bool // Result of an unquoted expansion, subject to field splitting
+	brk    bool // Separates two fields, as between the parameters of "$@"
+}
+
+// expandWords expands a list of words into command arguments. Brace
+// expansion comes first, so each resulting word is expanded on its own.
+func (e *expander) expandWords(words []*parser.Word) ([]string, error) {
+	args := make([]string, 0, len(words))
+	for _, w := range words {
+		for _, bw := range parser.ExpandBraces(w) {
+			fields, err := e.expandFields(bw)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, fields...)
+		}
+	}
+	return args, nil
+}
+
+// expandFields expands a word into zero or more fields. Unquoted expansion
+// results are split on the characters of IFS, then each field undergoes
+// pathname expansion.
+func (e *expander) expandFields(w *parser.Word) ([]string, error) {
+	parts, err := e.expandWordParts(w, false)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	env := app.CurrentEnv(e.ctx)
+	for _, field := range splitFields(parts, e.ifs()) {
+		matches, err := globField(env, field)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, matches...)
+	}
+	return fields, nil
+}
+
+// expandWord expands a word into a single string without field splitting,
+// as used for assignment values and redirection targets.
+func (e *expander) expandWord(w *parser.Word) (string, error) {
+	if w == nil {
+		return "", nil
+	}
+	parts, err := e.expandWordParts(w, false)
+	if err != nil {
+		return "", err
+	}
+	return joinParts(parts), nil
+}
+
+// expandPattern expands a word into a shell pattern. Quoted characters are
+// escaped so that they only match themselves.
+func (e *expander) expandPattern(w *parser.Word) (string, error) {
+	if w == nil {
+		return "", nil
+	}
+	parts, err := e.expandWordParts(w, false)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, fp := range parts {
+		switch {
+		case fp.brk:
+			sb.WriteByte(' ')
+		case fp.quoted:
+			sb.WriteString(escapePattern(fp.val))
+		default:
+			sb.WriteString(fp.val)
+		}
+	}
+	return sb.String(), nil
+}
+
+// expandWordParts performs all expansions on a word and returns the
+// resulting pieces with their quoting preserved. assign enables the tilde
+// expansions specific to assignment values.
+func (e *expander) expandWordParts(w *parser.Word, assign bool) ([]fieldPart, error) {
+	var parts []fieldPart
+	for i, part := range w.Parts {
+		if lit, ok := part.(*parser.Lit); ok && i == 0 {
+			parts = expandTilde(app.CurrentEnv(e.ctx), parts, lit.Value, assign, len(w.Parts) > 1)
+			continue
+		}
+		var err error
+		if parts, err = e.expandPart(parts, part, false); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+// expandPart appends the expansion of a single word part to parts.
+// quoted is true inside double quotes.
+func (e *expander) expandPart(parts []fieldPart, part parser.WordPart, quoted bool) ([]fieldPart, error) {
+	switch part := part.(type) {
+	case *parser.Lit:
+		return appendLit(parts, part.Value, quoted), nil
+	case *parser.SglQuoted:
+		return append(parts, fieldPart{val: part.Value, quoted: true}), nil
+	case *parser.DblQuoted:
+		// "" still produces an (empty) field, but "$@" without parameters produces none
+		if !hasAllParams(part) {
+			parts = append(parts, fieldPart{quoted: true})
+		}
+		for _, inner := range part.Parts {
+			var err error
+			if parts, err = e.expandPart(parts, inner, true); err != nil {
+				return nil, err
+			}
+		}
+		return parts, nil
+	case *parser.ParamExp:
+		if isAllParams(part) {
+			return e.appendAllParams(parts, part.Param, quoted), nil
+		}
+		if w, ok := e.paramWord(part); ok {
+			return e.appendParamWord(parts, w, quoted)
+		}
+		value, err := e.expandParam(part)
+		if err != nil {
+			return nil, err
+		}
+		return append(parts, fieldPart{val: value, quoted: quoted, split: !quoted}), nil
+	case *parser.CmdSubst:
+		value := e.runCmdSubst(part)
+		return append(parts, fieldPart{val: value, quoted: quoted, split: !quoted}), nil
+	case *parser.ArithmExp:
+		n, err := e.expandArithm(part.X)
+		if err != nil {
+			return nil, err
+		}
+		return append(parts, fieldPart{val: strconv.FormatInt(n, 10), quoted: quoted, split: !quoted}), nil
+	}
+	return parts, nil
+}
+
+// isAllParams reports whether pe is a plain $@ or $*, which expand to one
+// field per positional parameter.
+func isAllParams(pe *parser.ParamExp) bool {
+	return (pe.Param == "@" || pe.Param == "*") && !pe.Length && pe.Exp == nil && pe.Repl == nil
+}
+
+// hasAllParams reports whether a double-quoted string contains "$@".
+func hasAllParams(q *parser.DblQuoted) bool {
+	for _, part := range q.Parts {
+		if pe, ok := part.(*parser.ParamExp); ok && isAllParams(pe) && pe.Param == "@" {
+			return true
+		}
+	}
+	return false
+}
+
+// appendAllParams appends the positional parameters for $@ or $*. Each
+// parameter is a separate field, except for "$*", which joins them with
+// the first character of IFS.
+func (e *expander) appendAllParams(parts []fieldPart, param string, quoted bool) []fieldPart {
+	params := app.CurrentFrame(e.ctx).Params()
+	if param == "*" && quoted {
+		ifs := e.ifs()
+		return append(parts, fieldPart{val: strings.Join(params, ifs[:min(1, len(ifs))]), quoted: true})
+	}
+	for i, p := range params {
+		if i > 0 {
+			parts = append(parts, fieldPart{brk: true})
+		}
+		parts = append(parts, fieldPart{val: p, quoted: quoted, split: !quoted})
+	}
+	return parts
+}
+
+// appendLit appends literal text to parts, resolving backslash escapes.
+// Escaped characters are quoted; inside double quotes a backslash only
+// escapes '$', '`', '"', '\' and newline.
+func appendLit(parts []fieldPart, s string, quoted bool) []fieldPart {
+	if !strings.Contains(s, `\`) {
+		return append(parts, fieldPart{val: s, quoted: quoted})
+	}
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			parts = append(parts, fieldPart{val: sb.String(), quoted: quoted})
+			sb.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		next := s[i+1]
+		i++
+		switch {
+		case next == '\n':
+			// Line continuation: drop both characters
+		case quoted && !strings.ContainsRune("$`\"\\", rune(next)):
+			sb.WriteByte('\\')
+			sb.WriteByte(next)
+		default:
+			flush()
+			parts = append(parts, fieldPart{val: string(next), quoted: true})
+		}
+	}
+	flush()
+	return parts
+}
+
+// ifs returns the value of IFS, or its default when it is unset.
+func (e *expander) ifs() string {
+	ifs, ok := app.CurrentEnv(e.ctx).GetVar("IFS")
+	if !ok {
+		return defaultIFS
+	}
+	return ifs
+}
+
+// splitFields splits expanded word parts into fields on the characters of ifs.
+// A field is kept if it has any content or contains a quoted part, so
+// that "" yields an empty argument while an unquoted empty $VAR yields none.
+//
+// As POSIX has it, runs of IFS whitespace (space, tab and newline) count
+// as one separator and are ignored at the ends, while any other IFS
+// character ends a field, with the whitespace around it: with IFS=: the
+// value "a::b" splits into "a", "" and "b".
+func splitFields(parts []fieldPart, ifs string) [][]fieldPart {
+	var fields [][]fieldPart
+	var cur []fieldPart
+	keep := false
+	afterSpace := false // The last field was ended by whitespace alone
+	end := func() {
+		fields = append(fields, cur)
+		cur, keep = nil, false
+	}
+	for _, fp := range parts {
+		if fp.brk {
+			if keep {
+				end()
+			}
+			cur, keep, afterSpace = nil, false, false
+			continue
+		}
+		if !fp.split || ifs == "" {
+			cur = append(cur, fp)
+			if fp.quoted || fp.val != "" {
+				keep, afterSpace = true, false
+			}
+			continue
+		}
+		start := 0
+		for i := 0; i < len(fp.val); i++ {
+			c := fp.val[i]
+			if strings.IndexByte(ifs, c) < 0 {
+				continue
+			}
+			if i > start {
+				cur = append(cur, fieldPart{val: fp.val[start:i]})
+				keep = true
+			}
+			start = i + 1
+			switch {
+			case c == ' ' || c == '\t' || c == '\n':
+				if keep {
+					end()
+					afterSpace = true
+				}
+			case keep || !afterSpace:
+				end()
+				afterSpace = false
+			default:
+				// Joins the whitespace that ended the last field
+				afterSpace = false
+			}
+		}
+		if start < len(fp.val) {
+			cur = append(cur, fieldPart{val: fp.val[start:]})
+			keep, afterSpace = true, false
+		}
+	}
+	if keep {
+		end()
+	}
+	return fields
+}
+
+// joinParts concatenates the values of parts.
+func joinParts(parts []fieldPart) string {
+	if len(parts) == 1 {
+		return parts[0].val
+	}
+	var sb strings.Builder
+	for _, fp := range parts {
+		if fp.brk {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(fp.val)
+	}
+	return sb.String()
+}
+
+// lookupParam returns the value of a variable or special parameter and
+// whether it is set.
+func (e *expander) lookupParam(name string) (string, bool) {
+	appInstance := app.GetApp()
+	frame := app.CurrentFrame(e.ctx)
+	switch name {
+	case "?":
+		return strconv.Itoa(appInstance.LastStatus()), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	case "0":
+		return appInstance.ScriptName(), true
+	case "#":
+		return strconv.Itoa(len(frame.Params())), true
+	case "@", "*":
+		params := frame.Params()
+		return strings.Join(params, " "), len(params) > 0
+	case "-":
+		return shortOptionFlags(), true
+	case "!":
+		pid, ok := appInstance.LastBackgroundPid()
+		if !ok {
+			return "", false
+		}
+		return strconv.Itoa(pid), true
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		params := frame.Params()
+		if n < 1 || n > len(params) {
+			return "", false
+		}
+		return params[n-1], true
+	}
+	return app.CurrentEnv(e.ctx).GetVar(name)
+}
+
+// shortOptionFlags returns the value of $-, the letters of the enabled
+// options that have a single-letter flag.
+func shortOptionFlags() string {
+	appInstance := app.GetApp()
+	var flags []byte
+	for flag, name := range app.ShortOptions {
+		if appInstance.Option(name) {
+			flags = append(flags, flag)
+		}
+	}
+	slices.Sort(flags)
+	return string(flags)
+}
+
+// paramWord reports whether the ${a-w}, ${a:-w}, ${a+w} or ${a:+w}
+// expansion pe expands to its word w rather than to the parameter.
+func (e *expander) paramWord(pe *parser.ParamExp) (*parser.Word, bool) {
+	if pe.Length || pe.Repl != nil || pe.Exp == nil {
+		return nil, false
+	}
+	value, set := e.lookupParam(pe.Param)
+	switch op := pe.Exp.Op; op {
+	case parser.DefaultUnset, parser.DefaultUnsetOrNull:
+		return pe.Exp.Word, !set || (op == parser.DefaultUnsetOrNull && value == "")
+	case parser.AlternateUnset, parser.AlternateUnsetOrNull:
+		return pe.Exp.Word, set && (op == parser.AlternateUnset || value != "")
+	}
+	return nil, false
+}
+
+// appendParamWord appends the expansion of the word that replaces a
+// parameter. Unquoted, the quoted parts of the word stay whole while the
+// rest is split like the value of a parameter, so ${a:-"x y"} is one
+// field; quoted, all of it is.
+func (e *expander) appendParamWord(parts []fieldPart, w *parser.Word, quoted bool) ([]fieldPart, error) {
+	if w == nil {
+		return append(parts, fieldPart{quoted: quoted}), nil
+	}
+	wordParts, err := e.expandWordParts(w, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, fp := range wordParts {
+		if quoted {
+			fp.quoted, fp.split = true, false
+		} else if !fp.quoted {
+			fp.split = true
+		}
+		parts = append(parts, fp)
+	}
+	return parts, nil
+}
+
+// expandParam returns the value of a parameter expansion.
+func (e *expander) expandParam(pe *parser.ParamExp) (string, error) {
+	value, set := e.lookupParam(pe.Param)
+	if pe.Length {
+		return strconv.Itoa(utf8.RuneCountInString(value)), nil
+	}
+	if pe.Repl != nil {
+		return e.replaceParam(value, pe.Repl)
+	}
+	if pe.Exp == nil {
+		return value, nil
+	}
+
+	op := pe.Exp.Op
+	switch op {
+	case parser.DefaultUnset, parser.DefaultUnsetOrNull,
+		parser.AssignUnset, parser.AssignUnsetOrNull,
+		parser.ErrorUnset, parser.ErrorUnsetOrNull:
+		// The ':' forms also treat an empty value as unset
+		colon := op == parser.DefaultUnsetOrNull || op == parser.AssignUnsetOrNull || op == parser.ErrorUnsetOrNull
+		if set && (!colon || value != "") {
+			return value, nil
+		}
+		word, err := e.expandWord(pe.Exp.Word)
+		if err != nil {
+			return "", err
+		}
+		switch op {
+		case parser.AssignUnset, parser.AssignUnsetOrNull:
+			if !parser.IsValidName(pe.Param) {
+				return "", fmt.Errorf("$%s: cannot assign in this way", pe.Param)
+			}
+			app.CurrentEnv(e.ctx).SetVar(pe.Param, word)
+		case parser.ErrorUnset, parser.ErrorUnsetOrNull:
+			if word == "" {
+				word = "parameter null or not set"
+			}
+			// A non-interactive shell exits, as POSIX requires; a
+			// subshell such as a command substitution has its own exit
+			// state and only it ends
+			if s := builtins.Exit(e.ctx); s != nil && !s.Exiting && !app.GetApp().Interactive() {
+				s.Exiting = true
+				s.Status = 1
+			}
+			return "", fmt.Errorf("%s: %s", pe.Param, word)
+		}
+		return word, nil
+
+	case parser.AlternateUnset, parser.AlternateUnsetOrNull:
+		if !set || (op == parser.AlternateUnsetOrNull && value == "") {
+			return "", nil
+		}
+		return e.expandWord(pe.Exp.Word)
+
+	case parser.RemSmallPrefix, parser.RemLargePrefix, parser.RemSmallSuffix, parser.RemLargeSuffix:
+		pattern, err := e.expandPattern(pe.Exp.Word)
+		if err != nil {
+			return "", err
+		}
+		return removeAffix(value, pattern, op), nil
+	}
+	return value, nil
+}
+
+// removeAffix removes the shortest or longest prefix or suffix of value
+// matching pattern.
+func removeAffix(value, pattern string, op parser.ParExpOperator) string {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return value
+	}
+	// The value is cut at character boundaries only, so that a pattern
+	// like '?' takes a whole character
+	switch op {
+	case parser.RemSmallPrefix:
+		for i := 0; ; {
+			if re.MatchString(value[:i]) {
+				return value[i:]
+			}
+			if i == len(value) {
+				break
+			}
+			_, size := utf8.DecodeRuneInString(value[i:])
+			i += size
+		}
+	case parser.RemLargePrefix:
+		for i := len(value); ; {
+			if re.MatchString(value[:i]) {
+				return value[i:]
+			}
+			if i == 0 {
+				break
+			}
+			_, size := utf8.DecodeLastRuneInString(value[:i])
+			i -= size
+		}
+	case parser.RemSmallSuffix:
+		for i := len(value); ; {
+			if re.MatchString(value[i:]) {
+				return value[:i]
+			}
+			if i == 0 {
+				break
+			}
+			_, size := utf8.DecodeLastRuneInString(value[:i])
+			i -= size
+		}
+	case parser.RemLargeSuffix:
+		for i := 0; ; {
+			if re.MatchString(value[i:]) {
+				return value[:i]
+			}
+			if i == len(value) {
+				break
+			}
+			_, size := utf8.DecodeRuneInString(value[i:])
+			i += size
+		}
+	}
+	return value
+}
+
+// replaceParam implements ${NAME/orig/with} and its variants. The longest
+// match of orig is replaced, as in other shells.
+func (e *expander) replaceParam(value string, repl *parser.Replace) (string, error) {
+	pattern, err := e.expandPattern(repl.Orig)
+	if err != nil {
+		return "", err
+	}
+	with, err := e.expandWord(repl.With)
+	if err != nil {
+		return "", err
+	}
+	if pattern == "" {
+		return value, nil
+	}
+
+	expr := patternToRegexp(pattern)
+	switch repl.Anchor {
+	case '#':
+		expr = "^(?:" + expr + ")"
+	case '%':
+		expr = "(?:" + expr + ")$"
+	}
+	re, err := regexp.Compile("(?s)" + expr)
+	if err != nil {
+		return value, nil
+	}
+	re.Longest()
+	if repl.All {
+		return re.ReplaceAllLiteralString(value, with), nil
+	}
+	loc := re.FindStringIndex(value)
+	if loc == nil {
+		return value, nil
+	}
+	return value[:loc[0]] + with + value[loc[1]:], nil
+}
+
+// expandArithm expands the text of an arithmetic expression as if it were
+// in double quotes, then evaluates it.
+func (e *expander) expandArithm(w *parser.Word) (int64, error) {
+	if w == nil {
+		return 0, nil
+	}
+	var parts []fieldPart
+	for _, part := range w.Parts {
+		var err error
+		if parts, err = e.expandPart(parts, part, true); err != nil {
+			return 0, err
+		}
+	}
+	return arith.Eval(e.ctx, joinParts(parts))
+}
+
+// runCmdSubst runs the commands of a $(...) or `...` substitution in a
+// subshell and returns their output without trailing newlines. Like a
+// pipeline stage, the subshell changes only its own copy of the variables,
+// working directory and positional parameters.
+func (e *expander) runCmdSubst(cs *parser.CmdSubst) string {
+	var buf bytes.Buffer
+	markLaunched(e.ctx)
+	runStmts(subshellContext(e.ctx), cs.Stmts, stdio{in: e.std.in, out: &buf, err: e.std.err})
+	e.substRan = true
+	return strings.TrimRight(buf.String(), "\n")
+}
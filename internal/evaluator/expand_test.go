@@ -0,0 +1,178 @@
+package evaluator
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"testing"
+
+	"dush/internal/app"
+	"dush/internal/parser"
+)
+
+// expandArgs expands the arguments of the command line src, with vars set
+// in a copy of the shell's variables and no positional parameters.
+func expandArgs(t *testing.T, src string, vars map[string]string) ([]string, error) {
+	t.Helper()
+	f, err := parser.Parse("cmd " + src)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	env := app.CurrentEnv(context.Background()).Clone()
+	for name, value := range vars {
+		env.SetVar(name, value)
+	}
+	ctx := app.WithFrame(app.WithEnv(context.Background(), env), app.NewFrame(nil, nil))
+	e := &expander{
+		ctx: ctx,
+		std: stdio{in: os.Stdin, out: io.Discard, err: io.Discard},
+	}
+	return e.expandWords(f.Stmts[0].Cmd.(*parser.CallExpr).Args[1:])
+}
+
+func TestQuoteRemoval(t *testing.T) {
+	vars := map[string]string{"x": "1", "e": ""}
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`a"b"'c'`, []string{"abc"}},
+		{`"a b" 'c d'`, []string{"a b", "c d"}},
+		{`'$x' "$x" \$x`, []string{"$x", "1", "$x"}},
+		{`"a\"b\\c\$d\e\'"`, []string{`a"b\c$d\e\'`}},
+		{`'a\b' 'a"b'`, []string{`a\b`, `a"b`}},
+		{`a\ b \\ \'`, []string{"a b", `\`, "'"}},
+		{`"" ''`, []string{"", ""}},
+		{`$unset_var "$unset_var"`, []string{""}},
+		{"a\\\nb \"c\\\nd\"", []string{"ab", "cd"}},
+		{`\* "*" '?'`, []string{"*", "*", "?"}},
+		{`x"$e"y "$e"`, []string{"xy", ""}},
+		{`"$(echo "a  b")"`, []string{"a  b"}},
+	}
+	for _, tt := range tests {
+		got, err := expandArgs(t, tt.src, vars)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParamExpansion(t *testing.T) {
+	vars := map[string]string{"s": "path/to/file.tar.gz", "e": "", "w": "a b  c", "n": "é日x"}
+	tests := []struct {
+		src     string
+		want    []string
+		wantErr bool
+	}{
+		{`$s ${s} "$s"`, []string{"path/to/file.tar.gz", "path/to/file.tar.gz", "path/to/file.tar.gz"}, false},
+		{`${#s} ${#e} ${#_t_unset} ${#n}`, []string{"19", "0", "0", "3"}, false},
+
+		// Defaults and assignments
+		{`${_t_unset-def} "${e-def}" ${_t_unset:-def} ${e:-def} ${s:-def}`, []string{"def", "", "def", "def", "path/to/file.tar.gz"}, false},
+		{`${_t_unset:-"x y"} ${_t_unset:-x y}`, []string{"x y", "x", "y"}, false},
+		{`${_t_unset:-$w} "${_t_unset:-$w}" ${_t_unset:-'a  b'c} ${s:+"$w"}`, []string{"a", "b", "c", "a b  c", "a  bc", "a b  c"}, false},
+		{`${_t_unset:-${s##*.}} ${s:-$(exit 1)}`, []string{"gz", "path/to/file.tar.gz"}, false},
+		{`${_t_a=1} $_t_a "${e=x}" ${e:=x} $e`, []string{"1", "1", "", "x", "x"}, false},
+		{`${1=x}`, nil, true},
+
+		// Errors
+		{`"${e?msg}"`, []string{""}, false},
+		{`${_t_unset?}`, nil, true},
+		{`${e:?msg}`, nil, true},
+		{`${s:?msg}`, []string{"path/to/file.tar.gz"}, false},
+
+		// Alternatives
+		{`${s+alt} ${_t_unset+alt} ${e+alt} "${e:+alt}"`, []string{"alt", "alt", ""}, false},
+
+		// Prefixes and suffixes
+		{`${s#*/} ${s##*/} ${s%.*} ${s%%.*}`, []string{"to/file.tar.gz", "file.tar.gz", "path/to/file.tar", "path/to/file"}, false},
+		{`${s#"*"} ${s#x} ${s%"gz"}`, []string{"path/to/file.tar.gz", "path/to/file.tar.gz", "path/to/file.tar."}, false},
+		{`${n#?} ${n%?} ${n##*日}`, []string{"日x", "é日", "x"}, false},
+
+		// Replacements
+		{`${s/t/T} ${s//t/T} ${s/#p/P} ${s/%gz/GZ}`, []string{"paTh/to/file.tar.gz", "paTh/To/file.Tar.gz", "Path/to/file.tar.gz", "path/to/file.tar.GZ"}, false},
+		{`${s/o} ${s//./_} ${s/#x/y} ${s/%t/y}`, []string{"path/t/file.tar.gz", "path/to/file_tar_gz", "path/to/file.tar.gz", "path/to/file.tar.gz"}, false},
+		{`${s/*\//} "${w// /-}"`, []string{"file.tar.gz", "a-b--c"}, false},
+
+		// Splitting of the results
+		{`$w "$w" ${w#a}`, []string{"a", "b", "c", "a b  c", "b", "c"}, false},
+	}
+	for _, tt := range tests {
+		got, err := expandArgs(t, tt.src, vars)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.src, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestFieldSplitting(t *testing.T) {
+	tests := []struct {
+		ifs   string
+		unset bool // IFS is unset rather than ifs
+		src   string
+		value string
+		want  []string
+	}{
+		{"", true, `$v`, " a  b\tc\n", []string{"a", "b", "c"}},
+		{"", true, `x$v`, " a b ", []string{"x", "a", "b"}},
+		{"", true, `$v`, "", nil},
+		{"", true, `"$v"`, " a  b ", []string{" a  b "}},
+		{":", false, `$v`, "a::b:", []string{"a", "", "b"}},
+		{":", false, `$v`, ":a", []string{"", "a"}},
+		{":", false, `$v$v`, "a:", []string{"a", "a"}},
+		{":", false, `$v`, "a b", []string{"a b"}},
+		{": ", false, `$v`, " a :b: :c  d ", []string{"a", "b", "", "c", "d"}},
+		{" ", false, `$v`, "a\tb  c", []string{"a\tb", "c"}},
+		{"", false, `$v`, "a b:c", []string{"a b:c"}},
+		{"", false, `$v`, "", nil},
+		{"x", false, `$v"$v"`, "axb", []string{"a", "baxb"}},
+	}
+	for _, tt := range tests {
+		vars := map[string]string{"v": tt.value}
+		if !tt.unset {
+			vars["IFS"] = tt.ifs
+		}
+		got, err := expandArgs(t, tt.src, vars)
+		if err != nil {
+			t.Errorf("IFS=%q v=%q %s: %v", tt.ifs, tt.value, tt.src, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("IFS=%q v=%q %s: got %q, want %q", tt.ifs, tt.value, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestTildeExpansion(t *testing.T) {
+	vars := map[string]string{"HOME": "/home/u", "d": "~"}
+	cwd := app.GetApp().GetCurrentDir()
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`~ ~/x ~/`, []string{"/home/u", "/home/u/x", "/home/u/"}},
+		{`"~" \~ '~'/x a~ $d`, []string{"~", "~", "~/x", "a~", "~"}},
+		{`~+ ~+/x`, []string{cwd, cwd + "/x"}},
+		{`~_t_no_such_user ~"x"`, []string{"~_t_no_such_user", "~x"}},
+		{`a=~/x:~/y`, []string{"a=~/x:~/y"}},
+	}
+	for _, tt := range tests {
+		got, err := expandArgs(t, tt.src, vars)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/parser"
+)
+
+// maxFuncDepth limits the nesting of function calls, so that runaway
+// recursion fails instead of exhausting memory.
+const maxFuncDepth = 1000
+
+// callFunction runs a shell function with args as its positional
+// parameters and a fresh scope for `local` variables. The status is that
+// of `return` or of the last command run. The RETURN trap runs when the
+// function returns, still in its scope.
+func callFunction(ctx context.Context, name string, fn *app.Function, args []string, std stdio) int {
+	depth := 1
+	if outer := builtins.Func(ctx); outer != nil {
+		depth = outer.Depth + 1
+	}
+	if depth > maxFuncDepth {
+		fmt.Fprintf(std.err, "dush: %s: maximum function nesting level exceeded (%d)\n", name, maxFuncDepth)
+		return 1
+	}
+
+	frame := app.NewFrame(args, app.CurrentEnv(ctx))
+	defer frame.RestoreLocals()
+
+	// Loops of the caller cannot be left with break from inside the function
+	fnCtx := builtins.WithLoops(app.WithFrame(ctx, frame), &builtins.LoopState{})
+	fnCtx = builtins.WithFunc(fnCtx, &builtins.FuncState{Depth: depth})
+	status := runCommand(fnCtx, fn.Body, std)
+	app.GetApp().SetLastStatus(status)
+	runTrap(app.WithFrame(ctx, frame), app.TrapReturn, std)
+	return status
+}
+
+// isDeclBuiltin reports whether name is a builtin whose NAME=value
+// arguments are expanded like assignments.
+func isDeclBuiltin(name string) bool {
+	switch name {
+	case "declare", "export", "local":
+		return true
+	}
+	return false
+}
+
+// expandDeclArgs expands the words of a declaration builtin such as
+// `local x=$y`. Arguments that look like assignments are expanded like
+// assignment values, without field splitting or pathname expansion.
+func (e *expander) expandDeclArgs(words []*parser.Word) ([]string, error) {
+	var args []string
+	for i, w := range words {
+		if i == 0 || !isAssignWord(w) {
+			fields, err := e.expandWords(words[i : i+1])
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, fields...)
+			continue
+		}
+		// Expand the value after NAME= on its own, so that tildes in it are expanded
+		lit := w.Parts[0].(*parser.Lit)
+		name, rest, _ := strings.Cut(lit.Value, "=")
+		value := &parser.Word{Parts: w.Parts[1:]}
+		if rest != "" {
+			valueLit := &parser.Lit{ValuePos: lit.ValuePos + parser.Pos(len(name)+1), Value: rest}
+			value.Parts = append([]parser.WordPart{valueLit}, value.Parts...)
+		}
+		parts, err := e.expandWordParts(value, true)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, name+"="+joinParts(parts))
+	}
+	return args, nil
+}
+
+// isAssignWord reports whether w starts with an unquoted NAME=.
+func isAssignWord(w *parser.Word) bool {
+	lit, ok := w.Parts[0].(*parser.Lit)
+	if !ok {
+		return false
+	}
+	name, _, found := strings.Cut(lit.Value, "=")
+	return found && parser.IsValidName(name)
+}
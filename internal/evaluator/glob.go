@@ -0,0 +1,202 @@
+package evaluator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"dush/internal/app"
+)
+
+// globField performs pathname expansion on a field. Fields without unquoted
+// pattern characters are returned unchanged. When nothing matches, the
+// nullglob and failglob options decide between dropping the field, failing,
+// or keeping it literally. Relative patterns match in the current
+// directory of env.
+func globField(env *app.Env, field []fieldPart) ([]string, error) {
+	literal := joinParts(field)
+	appInstance := app.GetApp()
+	if appInstance.Option(app.OptNoglob) {
+		return []string{literal}, nil
+	}
+
+	var sb strings.Builder
+	hasMeta := false
+	for _, fp := range field {
+		if fp.quoted {
+			sb.WriteString(escapePattern(fp.val))
+			continue
+		}
+		sb.WriteString(fp.val)
+		hasMeta = hasMeta || hasPatternChars(fp.val)
+	}
+	if !hasMeta {
+		return []string{literal}, nil
+	}
+
+	matches := glob(sb.String(), env.GetCurrentDir(), appInstance.Option(app.OptDotglob))
+	if len(matches) > 0 {
+		return matches, nil
+	}
+	switch {
+	case appInstance.Option(app.OptFailglob):
+		return nil, fmt.Errorf("no match: %s", literal)
+	case appInstance.Option(app.OptNullglob):
+		return nil, nil
+	}
+	return []string{literal}, nil
+}
+
+// glob returns the sorted paths matching pattern. Relative patterns are
+// resolved against dir but matches are returned relative, as written.
+// A "**" path component matches any number of directories.
+func glob(pattern, dir string, dotglob bool) []string {
+	components := strings.Split(pattern, "/")
+	bases := []string{""}
+	if components[0] == "" {
+		// Absolute pattern
+		bases = []string{"/"}
+		components = components[1:]
+	}
+
+	for i, comp := range components {
+		last := i == len(components)-1
+		var next []string
+		switch {
+		case comp == "":
+			// A trailing slash only matches directories
+			if last {
+				for _, base := range bases {
+					if isDir(resolve(dir, base)) {
+						next = append(next, base+"/")
+					}
+				}
+			} else {
+				next = bases
+			}
+		case comp == "**":
+			for _, base := range bases {
+				next = append(next, walkDirs(dir, base, dotglob, last)...)
+			}
+		case !hasPatternChars(comp):
+			name := unescapePattern(comp)
+			for _, base := range bases {
+				next = append(next, joinGlob(base, name))
+			}
+		default:
+			re, err := compilePattern(comp)
+			if err != nil {
+				return nil
+			}
+			showHidden := dotglob || strings.HasPrefix(comp, ".")
+			for _, base := range bases {
+				entries, err := os.ReadDir(resolve(dir, base))
+				if err != nil {
+					continue
+				}
+				for _, e := range entries {
+					name := e.Name()
+					if strings.HasPrefix(name, ".") && !showHidden {
+						continue
+					}
+					if re.MatchString(name) {
+						next = append(next, joinGlob(base, name))
+					}
+				}
+			}
+		}
+		bases = next
+		if len(bases) == 0 {
+			return nil
+		}
+	}
+
+	// Literal components were not checked while matching
+	var matches []string
+	seen := make(map[string]bool)
+	for _, m := range bases {
+		if seen[m] {
+			continue
+		}
+		if _, err := os.Lstat(resolve(dir, m)); err == nil {
+			matches = append(matches, m)
+			seen[m] = true
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// walkDirs returns base and every directory below it. With includeFiles,
+// files are returned as well, for a pattern ending in "**".
+func walkDirs(dir, base string, dotglob, includeFiles bool) []string {
+	var results []string
+	root := resolve(dir, base)
+	if !isDir(root) {
+		return nil
+	}
+	if base != "" || !includeFiles {
+		results = append(results, base)
+	}
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") && !dotglob {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || includeFiles {
+			rel, _ := filepath.Rel(root, path)
+			results = append(results, joinGlob(base, filepath.ToSlash(rel)))
+		}
+		return nil
+	})
+	return results
+}
+
+// joinGlob joins a matched base path and a name, keeping the base as written.
+func joinGlob(base, name string) string {
+	switch {
+	case base == "":
+		return name
+	case strings.HasSuffix(base, "/"):
+		return base + name
+	}
+	return base + "/" + name
+}
+
+// resolve returns path resolved against dir if it is relative.
+func resolve(dir, path string) string {
+	if path == "" {
+		return dir
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// unescapePattern removes the backslashes quoting characters in a pattern.
+func unescapePattern(pattern string) string {
+	if !strings.Contains(pattern, `\`) {
+		return pattern
+	}
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		sb.WriteByte(pattern[i])
+	}
+	return sb.String()
+}
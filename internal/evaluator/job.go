@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"dush/internal/app"
+	"dush/internal/jobs"
+	"dush/internal/parser"
+	"dush/internal/signals"
+)
+
+// runForeground runs a statement as a foreground job and waits for it to
+// finish or to be stopped with Ctrl-Z. The job runs in its own goroutine
+// so that a stopped job can be left behind and resumed later with fg or
+// bg. While it is in the foreground, cancelling ctx cancels the job with
+// the same cause.
+func runForeground(ctx context.Context, stmt *parser.Stmt, std stdio) int {
+	job := app.NewJob(stmt.Source, true)
+	jobCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() { cancel(context.Cause(ctx)) })
+	defer stop()
+	// Interrupts cancel the job directly, so that it stops at once
+	defer signals.SetForeground(cancel)()
+
+	jobCtx = jobs.WithJob(jobCtx, job)
+	go func() {
+		defer cancel(nil)
+		job.Finish(runCommand(jobCtx, stmt.Cmd, std))
+	}()
+	return jobs.Foreground(job, std.err)
+}
+
+// runBackground starts a statement terminated by '&' as a background job
+// and returns 0 without waiting for it. The job runs like a subshell and
+// is not cancelled with the command that started it. Without job control
+// its standard input is /dev/null unless redirected.
+func runBackground(ctx context.Context, stmt *parser.Stmt, std stdio) int {
+	appInstance := app.GetApp()
+	job := app.NewJob(stmt.Source, false)
+	jobCtx := jobs.WithJob(subshellContext(context.WithoutCancel(ctx)), job)
+
+	var devNull *os.File
+	if !jobs.Enabled() && std.in == os.Stdin {
+		if f, err := os.Open(os.DevNull); err == nil {
+			devNull = f
+			std.in = f
+		}
+	}
+
+	id := appInstance.AddJob(job)
+	go func() {
+		status := runCommand(jobCtx, stmt.Cmd, std)
+		if devNull != nil {
+			devNull.Close()
+		}
+		job.Finish(status)
+	}()
+
+	// Wait for the first process so that $! and the job notice can show it
+	<-job.Launched()
+	pids := job.Pids()
+	if len(pids) == 0 {
+		if jobs.Enabled() {
+			fmt.Fprintf(std.err, "[%d]\n", id)
+		}
+		return 0
+	}
+	appInstance.SetLastBackgroundPid(pids[0])
+	if jobs.Enabled() {
+		fmt.Fprintf(std.err, "[%d] %d\n", id, pids[0])
+	}
+	return 0
+}
+
+// markLaunched records that the job running a command, if any, has begun
+// running commands that may take a while, so that '&' stops waiting for it
+// to start a process.
+func markLaunched(ctx context.Context) {
+	if job := jobs.FromContext(ctx); job != nil {
+		job.MarkLaunched()
+	}
+}
@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// patternToRegexp translates a shell pattern into the body of an equivalent
+// regular expression. '*' matches any string, '?' any character and '[...]'
+// a bracket expression; a backslash makes the next character literal.
+func patternToRegexp(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			} else {
+				sb.WriteString(`\\`)
+			}
+		case '[':
+			if class, n := bracketToRegexp(pattern[i:]); n > 0 {
+				sb.WriteString(class)
+				i += n - 1
+			} else {
+				sb.WriteString(`\[`)
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return sb.String()
+}
+
+// bracketToRegexp translates the bracket expression at the start of s. It
+// returns the regexp class and the number of bytes consumed, or 0 if s does
+// not start with a complete bracket expression.
+func bracketToRegexp(s string) (string, int) {
+	i := 1
+	var sb strings.Builder
+	sb.WriteByte('[')
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		sb.WriteByte('^')
+		i++
+	}
+	// A ']' right after the opening bracket is a literal member
+	if i < len(s) && s[i] == ']' {
+		sb.WriteString(`\]`)
+		i++
+	}
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ']':
+			sb.WriteByte(']')
+			return sb.String(), i + 1
+		case c == '[' && i+1 < len(s) && s[i+1] == ':':
+			// Character class such as [:alpha:], which regexp understands as is
+			end := strings.Index(s[i:], ":]")
+			if end < 0 {
+				return "", 0
+			}
+			sb.WriteString(s[i : i+end+2])
+			i += end + 2
+		case c == '\\' && i+1 < len(s):
+			sb.WriteString(regexp.QuoteMeta(s[i+1 : i+2]))
+			i += 2
+		case c == '\\' || c == '[' || c == '^':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+			i++
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0
+}
+
+// compilePattern compiles a shell pattern that must match a whole string.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?s)^(?:" + patternToRegexp(pattern) + ")$")
+}
+
+// matchPattern reports whether s matches the shell pattern as a whole.
+func matchPattern(pattern, s string) bool {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return pattern == s
+	}
+	return re.MatchString(s)
+}
+
+// hasPatternChars reports whether pattern contains unescaped special characters.
+func hasPatternChars(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// escapePattern quotes every character of s that is special in a pattern.
+func escapePattern(s string) string {
+	if !strings.ContainsAny(s, `*?[]\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`*?[]\`, s[i]) >= 0 {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
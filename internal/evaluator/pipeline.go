@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"dush/internal/app"
+	"dush/internal/parser"
+)
+
+// runPipeline runs every command of a pipeline concurrently, connecting the
+// stdout of each command to the stdin of the next with an OS pipe. Builtins
+// run in their own goroutine and write straight into the pipe; externals get
+// the pipe ends as their standard streams.
+//
+// The exit status is that of the last command, or with the pipefail option
+// that of the last command to fail.
+func runPipeline(ctx context.Context, pl *parser.Pipeline, std stdio) int {
+	if pl.Negated {
+		ctx = withoutErrexit(ctx)
+	}
+	statuses := make([]int, len(pl.Cmds))
+	var wg sync.WaitGroup
+
+	in := std.in
+	var inPipe *os.File // Read end feeding the current stage, owned by the shell
+	for i, cmd := range pl.Cmds {
+		stageIO := stdio{in: in, out: std.out, err: std.err}
+
+		var outPipe *os.File
+		var nextIn *os.File
+		if i < len(pl.Cmds)-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				fmt.Fprintf(std.err, "dush: cannot create pipe: %v\n", err)
+				if inPipe != nil {
+					inPipe.Close()
+				}
+				statuses[len(statuses)-1] = 1
+				break
+			}
+			stageIO.out = w
+			outPipe, nextIn = w, r
+		}
+
+		// Each stage runs like a subshell, on its own copy of the shell's state
+		stageCtx := subshellContext(ctx)
+		wg.Add(1)
+		go func(i int, cmd parser.Command, stageIO stdio, inPipe, outPipe *os.File) {
+			defer wg.Done()
+			statuses[i] = runCommand(stageCtx, cmd, stageIO)
+			// Closing our ends lets the next stage see EOF and the previous
+			// stage get EPIPE if it is still writing.
+			if outPipe != nil {
+				outPipe.Close()
+			}
+			if inPipe != nil {
+				inPipe.Close()
+			}
+		}(i, cmd, stageIO, inPipe, outPipe)
+
+		in, inPipe = nextIn, nextIn
+	}
+	wg.Wait()
+
+	status := statuses[len(statuses)-1]
+	if app.GetApp().Option(app.OptPipefail) {
+		for i := len(statuses) - 1; i >= 0; i-- {
+			if statuses[i] != 0 {
+				status = statuses[i]
+				break
+			}
+		}
+	}
+	if pl.Negated {
+		if status == 0 {
+			return 1
+		}
+		return 0
+	}
+	return status
+}
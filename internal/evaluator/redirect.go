@@ -0,0 +1,204 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/parser"
+)
+
+// fdTable maps the standard file descriptors 0-2 to the streams behind them
+// while redirections are applied.
+type fdTable [3]interface{}
+
+// applyRedirects applies redirs from left to right on top of std, expanding
+// their targets with exp. It returns
+// the resulting streams and a function that closes every file it opened;
+// the cleanup function must be called even when an error is returned.
+func applyRedirects(exp *expander, redirs []*parser.Redirect, std stdio) (stdio, func(), error) {
+	var opened []*os.File
+	cleanup := func() {
+		for _, f := range opened {
+			f.Close()
+		}
+	}
+	if len(redirs) == 0 {
+		return std, cleanup, nil
+	}
+
+	fds := fdTable{std.in, std.out, std.err}
+	for _, r := range redirs {
+		fd := r.N
+		if fd < 0 {
+			fd = 1
+			switch r.Op {
+			case parser.RdrIn, parser.RdrDupIn, parser.RdrInOut, parser.RdrHeredoc, parser.RdrHeredocTabs, parser.RdrHerestring:
+				fd = 0
+			}
+		}
+		if fd > 2 {
+			return std, cleanup, fmt.Errorf("%d: bad file descriptor", fd)
+		}
+
+		if r.Op == parser.RdrHeredoc || r.Op == parser.RdrHeredocTabs {
+			body, err := exp.expandHeredoc(r)
+			if err != nil {
+				return std, cleanup, err
+			}
+			fds[fd] = strings.NewReader(body)
+			continue
+		}
+
+		target, err := exp.expandWord(r.Word)
+		if err != nil {
+			return std, cleanup, err
+		}
+
+		op := r.Op
+		// ">&file" without a descriptor number is the same as "&>file".
+		if op == parser.RdrDupOut && r.N < 0 && target != "-" && !isNumber(target) {
+			op = parser.RdrAll
+		}
+
+		switch op {
+		case parser.RdrHerestring:
+			fds[fd] = strings.NewReader(target + "\n")
+		case parser.RdrDupIn, parser.RdrDupOut:
+			if target == "-" {
+				if fd == 0 {
+					fds[fd] = strings.NewReader("")
+				} else {
+					fds[fd] = io.Discard
+				}
+				continue
+			}
+			src, err := strconv.Atoi(target)
+			if err != nil || src < 0 || src > 2 {
+				return std, cleanup, fmt.Errorf("%s: bad file descriptor", target)
+			}
+			fds[fd] = fds[src]
+		default:
+			f, err := openRedirect(app.CurrentEnv(exp.ctx), op, target)
+			if err != nil {
+				return std, cleanup, err
+			}
+			opened = append(opened, f)
+			if op == parser.RdrAll || op == parser.RdrAllAppend {
+				fds[1], fds[2] = f, f
+			} else {
+				fds[fd] = f
+			}
+		}
+	}
+
+	in, inOK := fds[0].(io.Reader)
+	out, outOK := fds[1].(io.Writer)
+	errOut, errOK := fds[2].(io.Writer)
+	if !inOK || !outOK || !errOK {
+		return std, cleanup, fmt.Errorf("bad file descriptor")
+	}
+	return stdio{in: in, out: out, err: errOut}, cleanup, nil
+}
+
+// expandHeredoc returns the text of a here-document. Its body is expanded
+// like a double-quoted string, except that a backslash only escapes '$',
+// '`', '\' and newline, unless the delimiter was quoted.
+func (e *expander) expandHeredoc(r *parser.Redirect) (string, error) {
+	if r.Hdoc == nil {
+		return "", nil
+	}
+	_, literal := parser.HeredocDelim(r.Word)
+	var sb strings.Builder
+	for _, part := range r.Hdoc.Parts {
+		lit, ok := part.(*parser.Lit)
+		switch {
+		case ok && literal:
+			sb.WriteString(lit.Value)
+		case ok:
+			sb.WriteString(unescapeHeredoc(lit.Value))
+		default:
+			parts, err := e.expandPart(nil, part, true)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(joinParts(parts))
+		}
+	}
+	return sb.String(), nil
+}
+
+// unescapeHeredoc resolves the backslash escapes of here-document text.
+func unescapeHeredoc(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\\\n", s[i+1]) >= 0 {
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// openRedirect opens the file a redirection operator refers to, resolving
+// relative paths against the current directory of env.
+func openRedirect(env *app.Env, op parser.RedirOperator, name string) (*os.File, error) {
+	if name == "" {
+		return nil, fmt.Errorf("ambiguous redirect")
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(env.GetCurrentDir(), path)
+	}
+
+	var flag int
+	switch op {
+	case parser.RdrIn:
+		flag = os.O_RDONLY
+	case parser.RdrInOut:
+		flag = os.O_RDWR | os.O_CREATE
+	case parser.RdrAppend, parser.RdrAllAppend:
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	case parser.RdrOut, parser.RdrAll:
+		if app.GetApp().Option(app.OptNoclobber) {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				return nil, fmt.Errorf("%s: cannot overwrite existing file", name)
+			}
+		}
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case parser.RdrClobber:
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		if pathErr, ok := err.(*os.PathError); ok {
+			err = pathErr.Err
+		}
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return f, nil
+}
+
+// isNumber reports whether s is a non-empty string of decimal digits.
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/parser"
+)
+
+// SourceFile reads the file at path and runs its commands in the current
+// shell. If args is not empty it replaces the positional parameters while
+// the file runs. 'return' at the top level of the file stops it early, and
+// the RETURN trap runs when the file is done.
+//
+// The error is non-nil only if the file cannot be read; syntax errors are
+// reported on errOut with status 2.
+func SourceFile(ctx context.Context, path string, args []string, out io.Writer, errOut io.Writer) (int, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return 1, err
+	}
+	file, err := parser.Parse(string(src))
+	if err != nil {
+		fmt.Fprintf(errOut, "dush: %s: %v\n", path, err)
+		return 2, nil
+	}
+
+	if len(args) > 0 {
+		frame := app.CurrentFrame(ctx)
+		saved := frame.Params()
+		frame.SetParams(args)
+		defer frame.SetParams(saved)
+	}
+
+	depth := 0
+	if outer := builtins.Func(ctx); outer != nil {
+		depth = outer.Depth
+	}
+	if builtins.Exit(ctx) == nil {
+		ctx = builtins.WithExit(ctx, &builtins.ExitState{})
+	}
+	std := stdio{in: builtins.Stdin(ctx), out: out, err: errOut}
+	fileCtx := builtins.WithLoops(ctx, &builtins.LoopState{})
+	fileCtx = builtins.WithFunc(fileCtx, &builtins.FuncState{Depth: depth})
+	status := runStmts(fileCtx, file.Stmts, std)
+	app.GetApp().SetLastStatus(status)
+	runTrap(ctx, app.TrapReturn, std)
+	return status, nil
+}
+
+func init() {
+	builtins.SetSourcer(SourceFile)
+}
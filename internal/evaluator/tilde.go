@@ -0,0 +1,87 @@
+package evaluator
+
+import (
+	"os"
+	"os/user"
+	"strings"
+
+	"dush/internal/app"
+)
+
+// expandTilde performs tilde expansion on the unquoted literal s, which
+// starts a word, and appends the result to parts. In an assignment value,
+// a tilde following an unquoted ':' is expanded as well, as in PATH=~/bin:~/go/bin.
+// more is true when further parts follow s in the word; a tilde-prefix
+// running into them is left alone. The directories are those of env.
+func expandTilde(env *app.Env, parts []fieldPart, s string, assign, more bool) []fieldPart {
+	for {
+		var segment string
+		colon := false
+		if i := unescapedIndex(s, ':'); assign && i >= 0 {
+			segment, s, colon = s[:i], s[i+1:], true
+		} else {
+			segment, s = s, ""
+		}
+
+		expanded := false
+		if strings.HasPrefix(segment, "~") {
+			prefix, rest := segment, ""
+			if i := strings.IndexByte(segment, '/'); i >= 0 {
+				prefix, rest = segment[:i], segment[i:]
+			}
+			unterminated := rest == "" && !colon && more
+			if !unterminated && !strings.Contains(prefix, `\`) {
+				if dir, ok := tildeDir(env, prefix[1:]); ok {
+					parts = append(parts, fieldPart{val: dir, quoted: true})
+					parts = appendLit(parts, rest, false)
+					expanded = true
+				}
+			}
+		}
+		if !expanded {
+			parts = appendLit(parts, segment, false)
+		}
+		if !colon {
+			return parts
+		}
+		parts = append(parts, fieldPart{val: ":"})
+	}
+}
+
+// tildeDir returns the directory a tilde-prefix refers to: the home
+// directory for "~", the home of a user for "~user", and the current or
+// previous directory for "~+" and "~-".
+func tildeDir(env *app.Env, name string) (string, bool) {
+	switch name {
+	case "":
+		if home, ok := env.GetVar("HOME"); ok {
+			return home, true
+		}
+		home, err := os.UserHomeDir()
+		return home, err == nil
+	case "+":
+		return env.GetCurrentDir(), true
+	case "-":
+		prev := env.GetPreviousDir()
+		return prev, prev != ""
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return u.HomeDir, true
+}
+
+// unescapedIndex returns the index of the first c in s not escaped by a
+// backslash, or -1.
+func unescapedIndex(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
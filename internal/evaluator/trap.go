@@ -0,0 +1,93 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/parser"
+	"dush/internal/signals"
+)
+
+// noTrapsKey is the context key marking commands for which the DEBUG, ERR
+// and RETURN traps and the traps of signals do not run: those of trap
+// actions themselves and of subshells.
+type noTrapsKey struct{}
+
+// withoutTraps returns a copy of ctx in which traps do not run.
+func withoutTraps(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTrapsKey{}, true)
+}
+
+// trapsEnabled reports whether traps run for the commands of ctx.
+func trapsEnabled(ctx context.Context) bool {
+	off, _ := ctx.Value(noTrapsKey{}).(bool)
+	return !off
+}
+
+// runTrap runs the action registered with 'trap' for a condition, if any.
+// The action does not change $?, unless it exits the shell.
+func runTrap(ctx context.Context, name string, std stdio) {
+	if !trapsEnabled(ctx) {
+		return
+	}
+	action, ok := app.GetApp().Trap(name)
+	if !ok || action == "" {
+		return
+	}
+	file, err := parser.Parse(action)
+	if err != nil {
+		fmt.Fprintf(std.err, "dush: trap: %v\n", err)
+		return
+	}
+	appInstance := app.GetApp()
+	status := appInstance.LastStatus()
+	runStmts(withoutTraps(ctx), file.Stmts, std)
+	appInstance.SetLastStatus(status)
+}
+
+// runPendingTraps runs the traps of the signals received since they last
+// ran.
+func runPendingTraps(ctx context.Context, std stdio) {
+	if !trapsEnabled(ctx) {
+		return
+	}
+	for _, sig := range signals.TakePending() {
+		runTrap(ctx, signals.Name(sig), std)
+	}
+}
+
+// RunPendingTraps runs the traps of the signals the shell received while
+// no command was running.
+func RunPendingTraps(ctx context.Context, out io.Writer, errOut io.Writer) {
+	runPendingTraps(ctx, stdio{in: os.Stdin, out: out, err: errOut})
+}
+
+// RunExitTrap runs the EXIT trap when the shell exits with status, and
+// returns the status the shell exits with: that given to 'exit' in the
+// trap, or status. The trap runs once, even if the shell was interrupted.
+func RunExitTrap(ctx context.Context, status int, out io.Writer, errOut io.Writer) int {
+	appInstance := app.GetApp()
+	action, ok := appInstance.Trap(app.TrapExit)
+	if !ok {
+		return status
+	}
+	appInstance.ResetTrap(app.TrapExit)
+	file, err := parser.Parse(action)
+	if err != nil {
+		fmt.Fprintf(errOut, "dush: trap: %v\n", err)
+		return status
+	}
+	exit := &builtins.ExitState{}
+	ctx = builtins.WithExit(context.WithoutCancel(ctx), exit)
+	ctx = builtins.WithLoops(withoutTraps(ctx), &builtins.LoopState{})
+	appInstance.SetLastStatus(status)
+	runStmts(ctx, file.Stmts, stdio{in: os.Stdin, out: out, err: errOut})
+	if exit.Exiting {
+		return exit.Status
+	}
+	return status
+}
@@ -0,0 +1,217 @@
+// Package jobs implements job control: it runs the processes of a job in
+// their own process group, hands the terminal to the foreground job and
+// notices when a job is stopped with Ctrl-Z. The job table itself lives
+// in app.App.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"dush/internal/app"
+)
+
+// jobKey is the context key under which the running job is stored.
+type jobKey struct{}
+
+// WithJob returns a copy of ctx carrying j as the job its commands belong to.
+func WithJob(ctx context.Context, j *app.Job) context.Context {
+	return context.WithValue(ctx, jobKey{}, j)
+}
+
+// FromContext returns the job stored in ctx, or nil.
+func FromContext(ctx context.Context) *app.Job {
+	j, _ := ctx.Value(jobKey{}).(*app.Job)
+	return j
+}
+
+var (
+	ttyFd     = -1 // Terminal of an interactive shell with job control, or -1
+	shellPgid int  // Process group of the shell
+
+	procsMu sync.Mutex
+	procs   = make(map[int]*app.Job) // Running processes of all jobs, by pid
+)
+
+// Enabled reports whether job control is active: the shell runs
+// interactively on a terminal and the monitor option is set.
+func Enabled() bool {
+	return ttyFd >= 0 && app.GetApp().Option(app.OptMonitor)
+}
+
+// Start starts cmd as a process of job, in the job's process group when
+// job control is enabled. A nil job starts cmd on its own.
+func Start(cmd *exec.Cmd, job *app.Job) error {
+	if job == nil {
+		return cmd.Start()
+	}
+	control := Enabled()
+	foreground := job.Foreground()
+	pid, err := job.StartProcess(func(pgid int) (int, int, error) {
+		if control {
+			pgid = setProcessGroup(cmd, pgid, foreground)
+		}
+		if err := cmd.Start(); err != nil {
+			return 0, 0, err
+		}
+		if control && pgid == 0 {
+			pgid = cmd.Process.Pid
+		}
+		return cmd.Process.Pid, pgid, nil
+	})
+	if err != nil {
+		return err
+	}
+	procsMu.Lock()
+	procs[pid] = job
+	procsMu.Unlock()
+	return nil
+}
+
+// Wait waits for a command started with Start to exit.
+func Wait(cmd *exec.Cmd, job *app.Job) error {
+	err := cmd.Wait()
+	if job != nil {
+		pid := cmd.Process.Pid
+		procsMu.Lock()
+		delete(procs, pid)
+		procsMu.Unlock()
+		job.ProcessDone(pid)
+	}
+	return err
+}
+
+// updateStates checks the running processes for ones that were stopped or
+// continued by a signal, and updates the state of their jobs.
+func updateStates() {
+	procsMu.Lock()
+	defer procsMu.Unlock()
+	for pid, job := range procs {
+		stopped, continued := processState(pid)
+		switch {
+		case stopped:
+			job.SetState(app.JobStopped)
+			if job.ID() != 0 {
+				app.GetApp().MakeCurrentJob(job)
+			}
+		case continued:
+			job.SetState(app.JobRunning)
+		}
+	}
+}
+
+// Foreground waits for job to finish or stop while it owns the terminal.
+// A stopped job is added to the job table and a finished one removed from
+// it. The status is that of the job, or 128+SIGTSTP if it stopped, in
+// which case a newline is written to w to end the line with "^Z".
+func Foreground(job *app.Job, w io.Writer) int {
+	appInstance := app.GetApp()
+	job.SetForeground(true)
+	if pgid := job.Pgid(); pgid != 0 && Enabled() {
+		setTerminal(pgid)
+	}
+	state := job.WaitChange()
+	if Enabled() {
+		setTerminal(shellPgid)
+	}
+
+	if state == app.JobStopped {
+		fmt.Fprintln(w)
+		job.SetForeground(false)
+		if job.ID() == 0 {
+			appInstance.AddJob(job)
+		} else {
+			appInstance.MakeCurrentJob(job)
+		}
+		return StoppedStatus
+	}
+	if job.ID() != 0 {
+		appInstance.RemoveJob(job)
+	}
+	return job.Status()
+}
+
+// Continue resumes a stopped job, in the foreground or in the background.
+func Continue(job *app.Job, foreground bool) error {
+	job.SetForeground(foreground)
+	if job.State() != app.JobStopped {
+		return nil
+	}
+	if err := signalJob(job, sigCont); err != nil {
+		return err
+	}
+	job.SetState(app.JobRunning)
+	return nil
+}
+
+// Hangup sends SIGHUP to every job in the job table, as when the terminal
+// of the shell goes away. Stopped jobs are continued so they can exit.
+func Hangup() {
+	for _, job := range app.GetApp().Jobs() {
+		signalJob(job, sigHup)
+		if job.State() == app.JobStopped {
+			signalJob(job, sigCont)
+		}
+	}
+}
+
+// Format returns the line describing job in listings, like
+// "[1]+  Running                 sleep 10 &". With pids, the process
+// group or process IDs follow the job number.
+func Format(job *app.Job, pids bool) string {
+	current, previous := app.GetApp().CurrentJobs()
+	mark := ' '
+	switch job {
+	case current:
+		mark = '+'
+	case previous:
+		mark = '-'
+	}
+
+	state := job.State()
+	status := state.String()
+	if state == app.JobDone && job.Status() != 0 {
+		status = fmt.Sprintf("Exit %d", job.Status())
+	}
+	command := job.Command
+	if state == app.JobRunning {
+		command += " &"
+	}
+
+	line := fmt.Sprintf("[%d]%c  ", job.ID(), mark)
+	if pids {
+		ids := job.Pids()
+		if pgid := job.Pgid(); pgid != 0 {
+			ids = []int{pgid}
+		}
+		for _, pid := range ids {
+			line += fmt.Sprintf("%d ", pid)
+		}
+	}
+	return line + fmt.Sprintf("%-24s%s", status, command)
+}
+
+// Report writes a line for each job that stopped or finished since it was
+// last reported, then removes the finished jobs from the job table.
+func Report(w io.Writer) {
+	appInstance := app.GetApp()
+	var lines []string
+	for _, job := range appInstance.ChangedJobs() {
+		if job.State() == app.JobRunning {
+			continue
+		}
+		lines = append(lines, Format(job, false))
+	}
+	for _, job := range appInstance.Jobs() {
+		if job.State() == app.JobDone {
+			appInstance.RemoveJob(job)
+		}
+	}
+	if len(lines) > 0 {
+		fmt.Fprintln(w, strings.Join(lines, "\n"))
+	}
+}
@@ -0,0 +1,116 @@
+//go:build !windows
+
+package jobs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"dush/internal/app"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sigCont = syscall.SIGCONT
+	sigHup  = syscall.SIGHUP
+
+	// StoppedStatus is the exit status of a job stopped with Ctrl-Z.
+	StoppedStatus = 128 + int(syscall.SIGTSTP)
+)
+
+// Enable turns on job control for an interactive shell reading from the
+// terminal fd. The shell moves to its own process group, takes the
+// terminal and starts watching for stopped jobs. It fails on systems
+// where stopped jobs cannot be detected.
+func Enable(fd int) error {
+	if !detectsStops {
+		return fmt.Errorf("no job control: stopped jobs cannot be detected on this system")
+	}
+	pid := os.Getpid()
+	pgid := syscall.Getpgrp()
+	fg, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	if err != nil {
+		return err
+	}
+	if fg != pgid {
+		return fmt.Errorf("no job control: the shell is not in the foreground")
+	}
+	// Keyboard stop signals are meant for jobs; catching them keeps the
+	// shell running while leaving them at their default in children,
+	// which would inherit an ignored signal.
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGTSTP, syscall.SIGTTIN)
+	if pgid != pid {
+		if err := syscall.Setpgid(0, 0); err != nil {
+			return err
+		}
+		pgid = pid
+	}
+	ttyFd, shellPgid = fd, pgid
+	setTerminal(pgid)
+
+	chld := make(chan os.Signal, 16)
+	signal.Notify(chld, syscall.SIGCHLD)
+	go func() {
+		for range chld {
+			updateStates()
+		}
+	}()
+	return nil
+}
+
+// setProcessGroup arranges for cmd to join the process group pgid, or to
+// lead a new one if pgid is 0 or no longer exists. A foreground process
+// also takes the terminal. It returns the group the process will join.
+func setProcessGroup(cmd *exec.Cmd, pgid int, foreground bool) int {
+	if pgid != 0 && syscall.Kill(-pgid, 0) != nil {
+		pgid = 0
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.SysProcAttr.Pgid = pgid
+	if foreground {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = ttyFd
+	}
+	return pgid
+}
+
+// setTerminal makes pgid the foreground process group of the terminal.
+// SIGTTOU is ignored meanwhile, since the shell may be in the background.
+func setTerminal(pgid int) {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	unix.IoctlSetPointerInt(ttyFd, unix.TIOCSPGRP, pgid)
+}
+
+// ShellOwnsTerminal reports whether the process group of the shell is the
+// foreground group of its terminal, so that keyboard signals reach the
+// processes it started in that group as well.
+func ShellOwnsTerminal() bool {
+	fd := ttyFd
+	if fd < 0 {
+		fd = int(os.Stdin.Fd())
+	}
+	fg, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	return err == nil && fg == syscall.Getpgrp()
+}
+
+// signalJob sends sig to the process group of job, or to each of its
+// running processes if it has no group.
+func signalJob(job *app.Job, sig syscall.Signal) error {
+	if pgid := job.Pgid(); pgid != 0 {
+		return syscall.Kill(-pgid, sig)
+	}
+	for _, pid := range job.RunningPids() {
+		if err := syscall.Kill(pid, sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
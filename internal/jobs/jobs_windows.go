@@ -0,0 +1,44 @@
+//go:build windows
+
+package jobs
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+
+	"dush/internal/app"
+)
+
+const (
+	sigCont = syscall.Signal(0x12)
+	sigHup  = syscall.SIGHUP
+
+	// StoppedStatus is the exit status of a job stopped with Ctrl-Z.
+	StoppedStatus = 128 + 20
+)
+
+// Enable reports that job control is not available on Windows.
+func Enable(fd int) error {
+	return errors.New("no job control on this platform")
+}
+
+func setProcessGroup(cmd *exec.Cmd, pgid int, foreground bool) int {
+	return 0
+}
+
+func setTerminal(pgid int) {}
+
+// ShellOwnsTerminal reports true: the console delivers Ctrl-C to every
+// process attached to it.
+func ShellOwnsTerminal() bool {
+	return true
+}
+
+func processState(pid int) (stopped, continued bool) {
+	return false, false
+}
+
+func signalJob(job *app.Job, sig syscall.Signal) error {
+	return errors.New("no job control on this platform")
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// detectsStops is true since processState tells stopped jobs.
+const detectsStops = true
+
+// Codes of SIGCHLD in siginfo_t.
+const (
+	cldStopped   = 5
+	cldContinued = 6
+)
+
+// processState reports whether the child pid was stopped or continued by
+// a signal since the last call. Exits are left for exec.Cmd.Wait to reap.
+func processState(pid int) (stopped, continued bool) {
+	var info unix.Siginfo
+	err := unix.Waitid(unix.P_PID, pid, &info, unix.WSTOPPED|unix.WCONTINUED|unix.WNOHANG, nil)
+	if err != nil || info.Signo != int32(syscall.SIGCHLD) {
+		return false, false
+	}
+	return info.Code == cldStopped, info.Code == cldContinued
+}
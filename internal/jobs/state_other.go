@@ -0,0 +1,15 @@
+//go:build !linux && !windows
+
+package jobs
+
+// detectsStops is false since processState cannot tell stopped jobs, so
+// job control is not turned on: a stopped foreground job would be waited
+// for forever.
+const detectsStops = false
+
+// processState reports whether the child pid was stopped or continued.
+// Without waitid there is no way to ask without reaping the child, so
+// stopped jobs are not detected on this platform.
+func processState(pid int) (stopped, continued bool) {
+	return false, false
+}
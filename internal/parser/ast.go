@@ -0,0 +1,421 @@
+package parser
+
+import "strings"
+
+// Pos is a byte offset into the source that was parsed.
+type Pos int
+
+// Node is implemented by every element of the syntax tree.
+type Node interface {
+	Pos() Pos // Offset of the first byte belonging to the node
+	End() Pos // Offset just past the last byte belonging to the node
+}
+
+// File is the root of a parsed command line or script.
+type File struct {
+	Stmts []*Stmt
+}
+
+// Stmt is a single command terminated by a newline, ';' or '&'.
+type Stmt struct {
+	Position   Pos
+	EndPos     Pos
+	Cmd        Command
+	Background bool   // Terminated by '&'
+	Source     string // Text of the command, as shown in job listings
+}
+
+func (s *Stmt) Pos() Pos { return s.Position }
+func (s *Stmt) End() Pos { return s.EndPos }
+
+// Command is implemented by all command nodes.
+type Command interface {
+	Node
+	commandNode()
+}
+
+// CallExpr is a simple command: optional assignments, words and redirections.
+// Assignments that are not followed by any word set shell variables.
+type CallExpr struct {
+	Assigns  []*Assign
+	Args     []*Word
+	Redirs   []*Redirect
+	Position Pos
+	EndPos   Pos
+}
+
+func (c *CallExpr) Pos() Pos { return c.Position }
+func (c *CallExpr) End() Pos { return c.EndPos }
+
+// Pipeline is a sequence of commands joined by '|'.
+type Pipeline struct {
+	Position Pos
+	Negated  bool // Prefixed by '!'
+	Cmds     []Command
+}
+
+func (p *Pipeline) Pos() Pos { return p.Position }
+func (p *Pipeline) End() Pos { return p.Cmds[len(p.Cmds)-1].End() }
+
+// BinaryCmd joins two commands with '&&' or '||'.
+type BinaryCmd struct {
+	OpPos Pos
+	Op    BinCmdOperator
+	X, Y  Command
+}
+
+func (b *BinaryCmd) Pos() Pos { return b.X.Pos() }
+func (b *BinaryCmd) End() Pos { return b.Y.End() }
+
+// ArithmCmd is an arithmetic command: ((expr)). Its exit status is 0 if
+// the expression is non-zero.
+type ArithmCmd struct {
+	Left, Right Pos   // Positions of the opening "((" and the closing "))"
+	X           *Word // Expression text, expanded before evaluation; nil when empty
+}
+
+func (a *ArithmCmd) Pos() Pos { return a.Left }
+func (a *ArithmCmd) End() Pos { return a.Right + 2 }
+
+// Block is a group of commands run in the current shell: { list; }.
+type Block struct {
+	Lbrace, Rbrace Pos
+	Stmts          []*Stmt
+	Redirs         []*Redirect
+}
+
+func (b *Block) Pos() Pos { return b.Lbrace }
+func (b *Block) End() Pos { return redirsEnd(b.Rbrace+1, b.Redirs) }
+
+// FuncDecl defines a shell function: name() body or function name body.
+// The body is a compound command, usually a Block.
+type FuncDecl struct {
+	Position Pos
+	Name     string
+	Body     Command
+	Source   string // The definition as written
+}
+
+func (f *FuncDecl) Pos() Pos { return f.Position }
+func (f *FuncDecl) End() Pos { return f.Body.End() }
+
+// IfClause is an if command. An elif branch is represented by an Else
+// clause with a condition, a final else branch by one without.
+type IfClause struct {
+	Position Pos // Position of the 'if', 'elif' or 'else'
+	FiPos    Pos
+	Cond     []*Stmt // Empty for an else branch
+	Then     []*Stmt
+	Else     *IfClause
+	Redirs   []*Redirect
+}
+
+func (c *IfClause) Pos() Pos { return c.Position }
+func (c *IfClause) End() Pos { return redirsEnd(c.FiPos+2, c.Redirs) }
+
+// WhileClause is a while or until loop.
+type WhileClause struct {
+	WhilePos, DonePos Pos
+	Until             bool
+	Cond              []*Stmt
+	Do                []*Stmt
+	Redirs            []*Redirect
+}
+
+func (w *WhileClause) Pos() Pos { return w.WhilePos }
+func (w *WhileClause) End() Pos { return redirsEnd(w.DonePos+4, w.Redirs) }
+
+// ForClause is a for loop over a list of words: for NAME in words; do ...; done.
+type ForClause struct {
+	ForPos, DonePos Pos
+	Name            string
+	HasIn           bool    // Without 'in', the loop is over the positional parameters
+	Items           []*Word // Expanded like command arguments
+	Do              []*Stmt
+	Redirs          []*Redirect
+}
+
+func (f *ForClause) Pos() Pos { return f.ForPos }
+func (f *ForClause) End() Pos { return redirsEnd(f.DonePos+4, f.Redirs) }
+
+// ArithmForClause is a C-style for loop: for ((init; cond; post)); do ...; done.
+type ArithmForClause struct {
+	ForPos, DonePos Pos
+	Init, Cond      *Word // nil when empty; an empty condition is true
+	Post            *Word
+	Do              []*Stmt
+	Redirs          []*Redirect
+}
+
+func (f *ArithmForClause) Pos() Pos { return f.ForPos }
+func (f *ArithmForClause) End() Pos { return redirsEnd(f.DonePos+4, f.Redirs) }
+
+// CaseClause is a case command: case word in pattern) ...;; esac.
+type CaseClause struct {
+	CasePos, EsacPos Pos
+	Word             *Word
+	Items            []*CaseItem
+	Redirs           []*Redirect
+}
+
+func (c *CaseClause) Pos() Pos { return c.CasePos }
+func (c *CaseClause) End() Pos { return redirsEnd(c.EsacPos+4, c.Redirs) }
+
+// CaseOperator ends the commands of a CaseItem.
+type CaseOperator int
+
+const (
+	CaseBreak       CaseOperator = iota // ;;
+	CaseFallthrough                     // ;& runs the next item's commands
+	CaseResume                          // ;;& tests the next patterns
+)
+
+// CaseItem is a list of patterns and the commands run when one matches.
+type CaseItem struct {
+	Patterns []*Word
+	Stmts    []*Stmt
+	Op       CaseOperator
+}
+
+// redirsEnd returns the end of a compound command whose closing keyword
+// ends at end, taking trailing redirections into account.
+func redirsEnd(end Pos, redirs []*Redirect) Pos {
+	if len(redirs) > 0 {
+		return redirs[len(redirs)-1].End()
+	}
+	return end
+}
+
+func (*CallExpr) commandNode()        {}
+func (*Pipeline) commandNode()        {}
+func (*BinaryCmd) commandNode()       {}
+func (*ArithmCmd) commandNode()       {}
+func (*Block) commandNode()           {}
+func (*FuncDecl) commandNode()        {}
+func (*IfClause) commandNode()        {}
+func (*WhileClause) commandNode()     {}
+func (*ForClause) commandNode()       {}
+func (*ArithmForClause) commandNode() {}
+func (*CaseClause) commandNode()      {}
+
+// BinCmdOperator is the operator of a BinaryCmd.
+type BinCmdOperator int
+
+const (
+	AndIf BinCmdOperator = iota // &&
+	OrIf                        // ||
+)
+
+func (o BinCmdOperator) String() string {
+	if o == AndIf {
+		return "&&"
+	}
+	return "||"
+}
+
+// Assign is a NAME=value or NAME+=value assignment.
+type Assign struct {
+	Position Pos
+	Name     string
+	Append   bool  // NAME+=value
+	Value    *Word // nil for "NAME="
+}
+
+func (a *Assign) Pos() Pos { return a.Position }
+func (a *Assign) End() Pos {
+	if a.Value != nil {
+		return a.Value.End()
+	}
+	end := a.Position + Pos(len(a.Name)) + 1
+	if a.Append {
+		end++
+	}
+	return end
+}
+
+// RedirOperator is the operator of a Redirect.
+type RedirOperator int
+
+const (
+	RdrIn          RedirOperator = iota // <
+	RdrOut                              // >
+	RdrAppend                           // >>
+	RdrClobber                          // >|
+	RdrInOut                            // <>
+	RdrDupIn                            // <&
+	RdrDupOut                           // >&
+	RdrAll                              // &>
+	RdrAllAppend                        // &>>
+	RdrHeredoc                          // <<
+	RdrHeredocTabs                      // <<-
+	RdrHerestring                       // <<<
+)
+
+var redirOpStrings = map[RedirOperator]string{
+	RdrIn:          "<",
+	RdrOut:         ">",
+	RdrAppend:      ">>",
+	RdrClobber:     ">|",
+	RdrInOut:       "<>",
+	RdrDupIn:       "<&",
+	RdrDupOut:      ">&",
+	RdrAll:         "&>",
+	RdrAllAppend:   "&>>",
+	RdrHeredoc:     "<<",
+	RdrHeredocTabs: "<<-",
+	RdrHerestring:  "<<<",
+}
+
+func (o RedirOperator) String() string { return redirOpStrings[o] }
+
+// Redirect is a redirection such as "2>&1" or "< input.txt". For a
+// here-document, Word is the delimiter.
+type Redirect struct {
+	OpPos Pos
+	Op    RedirOperator
+	N     int // Explicit file descriptor, or -1 when omitted
+	Word  *Word
+	// Hdoc is the body of a here-document, nil when empty. If the delimiter
+	// is quoted it is made of literals taken as is; otherwise it may hold
+	// expansions and a backslash only escapes '$', '`', '\' and newline.
+	Hdoc *Word
+}
+
+func (r *Redirect) Pos() Pos { return r.OpPos }
+func (r *Redirect) End() Pos { return r.Word.End() }
+
+// Word is a single shell word made of one or more adjacent parts,
+// e.g. foo"bar"'baz' is one Word with three parts.
+type Word struct {
+	Parts []WordPart
+}
+
+func (w *Word) Pos() Pos { return w.Parts[0].Pos() }
+func (w *Word) End() Pos { return w.Parts[len(w.Parts)-1].End() }
+
+// Lit returns the word's value if it consists of a single unquoted literal
+// without any escapes, and "" otherwise.
+func (w *Word) Lit() string {
+	if len(w.Parts) != 1 {
+		return ""
+	}
+	if lit, ok := w.Parts[0].(*Lit); ok && !strings.Contains(lit.Value, `\`) {
+		return lit.Value
+	}
+	return ""
+}
+
+// WordPart is implemented by all nodes that can make up a Word.
+type WordPart interface {
+	Node
+	wordPartNode()
+}
+
+// Lit is literal text. Backslash escapes are kept as written in Value and
+// are resolved during expansion.
+type Lit struct {
+	ValuePos Pos
+	Value    string
+}
+
+func (l *Lit) Pos() Pos { return l.ValuePos }
+func (l *Lit) End() Pos { return l.ValuePos + Pos(len(l.Value)) }
+
+// SglQuoted is a '...' string; its Value excludes the quotes.
+type SglQuoted struct {
+	Left, Right Pos
+	Value       string
+}
+
+func (q *SglQuoted) Pos() Pos { return q.Left }
+func (q *SglQuoted) End() Pos { return q.Right + 1 }
+
+// DblQuoted is a "..." string.
+type DblQuoted struct {
+	Left, Right Pos
+	Parts       []WordPart
+}
+
+func (q *DblQuoted) Pos() Pos { return q.Left }
+func (q *DblQuoted) End() Pos { return q.Right + 1 }
+
+// ParamExp is a parameter expansion: $NAME, ${NAME} or one of the
+// ${NAME<op>word} forms.
+type ParamExp struct {
+	Dollar Pos
+	Rbrace Pos    // Position of the closing '}'; unused when Short
+	Short  bool   // $NAME rather than ${NAME}
+	Param  string // Variable name, positional number or special character
+	Length bool   // ${#NAME}
+	Exp    *Expansion
+	Repl   *Replace
+}
+
+func (pe *ParamExp) Pos() Pos { return pe.Dollar }
+func (pe *ParamExp) End() Pos {
+	if pe.Short {
+		return pe.Dollar + 1 + Pos(len(pe.Param))
+	}
+	return pe.Rbrace + 1
+}
+
+// CmdSubst is a command substitution: $(stmts) or `stmts`.
+type CmdSubst struct {
+	Left, Right Pos
+	Stmts       []*Stmt
+	// Backquotes is true for the `...` form. The statements are parsed from
+	// the unescaped text between the backquotes, so their positions are
+	// relative to it.
+	Backquotes bool
+}
+
+func (cs *CmdSubst) Pos() Pos { return cs.Left }
+func (cs *CmdSubst) End() Pos { return cs.Right + 1 }
+
+// ArithmExp is an arithmetic expansion: $((expr)).
+type ArithmExp struct {
+	Left, Right Pos   // Positions of the '$' and the closing "))"
+	X           *Word // Expression text, expanded before evaluation; nil when empty
+}
+
+func (a *ArithmExp) Pos() Pos { return a.Left }
+func (a *ArithmExp) End() Pos { return a.Right + 2 }
+
+// ParExpOperator is the operator of an Expansion.
+type ParExpOperator int
+
+const (
+	DefaultUnset         ParExpOperator = iota // ${a-w}
+	DefaultUnsetOrNull                         // ${a:-w}
+	AssignUnset                                // ${a=w}
+	AssignUnsetOrNull                          // ${a:=w}
+	ErrorUnset                                 // ${a?w}
+	ErrorUnsetOrNull                           // ${a:?w}
+	AlternateUnset                             // ${a+w}
+	AlternateUnsetOrNull                       // ${a:+w}
+	RemSmallPrefix                             // ${a#w}
+	RemLargePrefix                             // ${a##w}
+	RemSmallSuffix                             // ${a%w}
+	RemLargeSuffix                             // ${a%%w}
+)
+
+// Expansion is the ${NAME<op>word} part of a ParamExp.
+type Expansion struct {
+	Op   ParExpOperator
+	Word *Word // nil when the word is empty
+}
+
+// Replace is the ${NAME/orig/with} part of a ParamExp.
+type Replace struct {
+	All    bool  // ${a//x/y}
+	Anchor byte  // '#' or '%' for ${a/#x/y} and ${a/%x/y}, otherwise 0
+	Orig   *Word // nil when empty
+	With   *Word // nil when empty
+}
+
+func (*Lit) wordPartNode()       {}
+func (*SglQuoted) wordPartNode() {}
+func (*DblQuoted) wordPartNode() {}
+func (*ParamExp) wordPartNode()  {}
+func (*CmdSubst) wordPartNode()  {}
+func (*ArithmExp) wordPartNode() {}
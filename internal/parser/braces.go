@@ -0,0 +1,276 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// braceItem is a unit of a word during brace expansion: either a single
+// unquoted byte of a Lit, or a whole part that braces cannot look into.
+type braceItem struct {
+	b       byte
+	pos     Pos
+	escaped bool     // b was preceded by a backslash, which is kept
+	part    WordPart // Set for quoted strings and expansions
+}
+
+// special reports whether it is the unquoted byte c.
+func (it braceItem) special(c byte) bool {
+	return it.part == nil && !it.escaped && it.b == c
+}
+
+// ExpandBraces performs brace expansion on w, returning the words it
+// expands to: "a{b,c}d" becomes "abd" and "acd", "{1..3}" becomes "1",
+// "2" and "3". It works on the syntax, before any other expansion, so
+// only unquoted braces and commas are significant. A word without a valid
+// brace expression is returned as is.
+func ExpandBraces(w *Word) []*Word {
+	hasBrace := false
+	for _, part := range w.Parts {
+		if lit, ok := part.(*Lit); ok && strings.IndexByte(lit.Value, '{') >= 0 {
+			hasBrace = true
+			break
+		}
+	}
+	if !hasBrace {
+		return []*Word{w}
+	}
+
+	var items []braceItem
+	for _, part := range w.Parts {
+		lit, ok := part.(*Lit)
+		if !ok {
+			items = append(items, braceItem{part: part})
+			continue
+		}
+		for i := 0; i < len(lit.Value); i++ {
+			it := braceItem{b: lit.Value[i], pos: lit.ValuePos + Pos(i)}
+			if it.b == '\\' && i+1 < len(lit.Value) {
+				i++
+				it.b, it.escaped = lit.Value[i], true
+			}
+			items = append(items, it)
+		}
+	}
+
+	var words []*Word
+	for _, its := range expandBraceItems(items) {
+		// Empty alternatives such as the one in "{,a}" produce no word
+		if len(its) > 0 {
+			words = append(words, braceWord(its))
+		}
+	}
+	return words
+}
+
+// expandBraceItems expands the first brace expression in items, then
+// recursively the alternatives and the rest of the word.
+func expandBraceItems(items []braceItem) [][]braceItem {
+	for open := 0; open < len(items); open++ {
+		if !items[open].special('{') {
+			continue
+		}
+		alts, end := braceAlternatives(items, open)
+		if alts == nil {
+			continue
+		}
+		prefix := items[:open]
+		suffixes := expandBraceItems(items[end+1:])
+		var results [][]braceItem
+		for _, alt := range alts {
+			for _, a := range expandBraceItems(alt) {
+				for _, s := range suffixes {
+					word := make([]braceItem, 0, len(prefix)+len(a)+len(s))
+					word = append(word, prefix...)
+					word = append(word, a...)
+					word = append(word, s...)
+					results = append(results, word)
+				}
+			}
+		}
+		return results
+	}
+	return [][]braceItem{items}
+}
+
+// braceAlternatives returns the alternatives of the brace expression
+// opening at items[open] and the index of its closing brace. It returns
+// nil if the braces are unbalanced or hold neither a comma nor a sequence.
+func braceAlternatives(items []braceItem, open int) ([][]braceItem, int) {
+	depth := 0
+	var commas []int
+	for i := open + 1; i < len(items); i++ {
+		switch {
+		case items[i].special('{'):
+			depth++
+		case items[i].special('}') && depth > 0:
+			depth--
+		case items[i].special('}'):
+			inner := items[open+1 : i]
+			if len(commas) == 0 {
+				seq := braceSequence(inner)
+				if seq == nil {
+					return nil, 0
+				}
+				return seq, i
+			}
+			var alts [][]braceItem
+			start := open + 1
+			for _, c := range append(commas, i) {
+				alts = append(alts, items[start:c])
+				start = c + 1
+			}
+			return alts, i
+		case items[i].special(',') && depth == 0:
+			commas = append(commas, i)
+		}
+	}
+	return nil, 0
+}
+
+// braceSequence expands the body of a {x..y} or {x..y..incr} sequence,
+// where x and y are both integers or both single letters. It returns nil
+// if inner is not a sequence.
+func braceSequence(inner []braceItem) [][]braceItem {
+	var sb strings.Builder
+	for _, it := range inner {
+		if it.part != nil || it.escaped {
+			return nil
+		}
+		sb.WriteByte(it.b)
+	}
+	fields := strings.Split(sb.String(), "..")
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil
+	}
+	incr := 1
+	if len(fields) == 3 {
+		n, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil
+		}
+		incr = n
+	}
+	if incr == 0 {
+		incr = 1
+	}
+	pos := inner[0].pos
+
+	var values []string
+	x, errX := strconv.Atoi(fields[0])
+	y, errY := strconv.Atoi(fields[1])
+	switch {
+	case errX == nil && errY == nil:
+		// Zero padding on either end pads every value to the same width
+		width := 0
+		if isPadded(fields[0]) || isPadded(fields[1]) {
+			width = max(len(fields[0]), len(fields[1]))
+		}
+		seq := sequence(x, y, incr)
+		if seq == nil {
+			return nil
+		}
+		for _, n := range seq {
+			s := strconv.Itoa(n)
+			if width > 0 {
+				s = padNumber(n, width)
+			}
+			values = append(values, s)
+		}
+	case len(fields[0]) == 1 && len(fields[1]) == 1 && isLetter(fields[0][0]) && isLetter(fields[1][0]):
+		for _, n := range sequence(int(fields[0][0]), int(fields[1][0]), incr) {
+			values = append(values, string(rune(n)))
+		}
+	default:
+		return nil
+	}
+
+	alts := make([][]braceItem, len(values))
+	for i, v := range values {
+		for j := 0; j < len(v); j++ {
+			alts[i] = append(alts[i], braceItem{b: v[j], pos: pos})
+		}
+	}
+	return alts
+}
+
+// maxSequence is the most values a sequence expands to; longer ones are
+// left as they are written.
+const maxSequence = 1 << 20
+
+// sequence returns the integers from x to y, in either direction, stepping
+// by the size of incr, or nil if there are more than maxSequence of them.
+// The count is worked out first, in unsigned arithmetic that cannot
+// overflow, so that ranges near the limits of int end.
+func sequence(x, y, incr int) []int {
+	step := uint64(incr)
+	if incr < 0 {
+		step = -step
+	}
+	var span uint64
+	if x <= y {
+		span = uint64(y) - uint64(x)
+	} else {
+		span = uint64(x) - uint64(y)
+	}
+	count := span/step + 1
+	if count > maxSequence {
+		return nil
+	}
+	seq := make([]int, count)
+	for i := range seq {
+		// Wraps back into range, as the values lie between x and y
+		if x <= y {
+			seq[i] = int(uint64(x) + uint64(i)*step)
+		} else {
+			seq[i] = int(uint64(x) - uint64(i)*step)
+		}
+	}
+	return seq
+}
+
+// isPadded reports whether the number s has a leading zero, as in "01".
+func isPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+// padNumber formats n with leading zeros to width characters, sign included.
+func padNumber(n, width int) string {
+	s := strconv.Itoa(n)
+	if digits, ok := strings.CutPrefix(s, "-"); ok {
+		return "-" + strings.Repeat("0", max(0, width-1-len(digits))) + digits
+	}
+	return strings.Repeat("0", max(0, width-len(s))) + s
+}
+
+func isLetter(b byte) bool { return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') }
+
+// braceWord rebuilds a Word from brace items, merging runs of bytes into Lits.
+func braceWord(items []braceItem) *Word {
+	w := &Word{}
+	var sb strings.Builder
+	var litPos Pos
+	flush := func() {
+		if sb.Len() > 0 {
+			w.Parts = append(w.Parts, &Lit{ValuePos: litPos, Value: sb.String()})
+			sb.Reset()
+		}
+	}
+	for _, it := range items {
+		if it.part != nil {
+			flush()
+			w.Parts = append(w.Parts, it.part)
+			continue
+		}
+		if sb.Len() == 0 {
+			litPos = it.pos
+		}
+		if it.escaped {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(it.b)
+	}
+	flush()
+	return w
+}
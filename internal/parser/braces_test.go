@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		// Alternatives
+		{"a{b,c}d", []string{"abd", "acd"}},
+		{"{a,b}{1,2}", []string{"a1", "a2", "b1", "b2"}},
+		{"{a,{b,c}}x", []string{"ax", "bx", "cx"}},
+		{"{,a}b", []string{"b", "ab"}},
+		{`{a,"b c",$x}`, []string{"a", `"b c"`, "${x}"}},
+		{"$x{1,2}", []string{"${x}1", "${x}2"}},
+
+		// Not brace expressions
+		{"{a}", []string{"{a}"}},
+		{"{a,b", []string{"{a,b"}},
+		{"a}", []string{"a}"}},
+		{`\{a,b}`, []string{`\{a,b}`}},
+		{`{a\,b}`, []string{`{a\,b}`}},
+		{`"{a,b}"`, []string{`"{a,b}"`}},
+		{"{a..3}", []string{"{a..3}"}},
+		{"{1..}", []string{"{1..}"}},
+		{"{1..2..x}", []string{"{1..2..x}"}},
+		{"{ab..c}", []string{"{ab..c}"}},
+
+		// Sequences
+		{"{1..3}", []string{"1", "2", "3"}},
+		{"{3..1}", []string{"3", "2", "1"}},
+		{"{-1..1}", []string{"-1", "0", "1"}},
+		{"{1..10..3}", []string{"1", "4", "7", "10"}},
+		{"{10..1..3}", []string{"10", "7", "4", "1"}},
+		{"{1..10..-3}", []string{"1", "4", "7", "10"}},
+		{"{1..3..0}", []string{"1", "2", "3"}},
+		{"{5..5}", []string{"5"}},
+		{"x{1..2}y{a,b}", []string{"x1ya", "x1yb", "x2ya", "x2yb"}},
+
+		// Zero padding
+		{"{01..3}", []string{"01", "02", "03"}},
+		{"{1..010..4}", []string{"001", "005", "009"}},
+		{"{-05..5..5}", []string{"-05", "000", "005"}},
+		{"{05..-3..3}", []string{"05", "02", "-1"}},
+		{"{0..2}", []string{"0", "1", "2"}},
+
+		// Letters
+		{"{a..e..2}", []string{"a", "c", "e"}},
+		{"{z..x}", []string{"z", "y", "x"}},
+		{"{Y..b..3}", []string{"Y", "\\", "_", "b"}},
+
+		// Limits
+		{"{9223372036854775806..9223372036854775807}", []string{"9223372036854775806", "9223372036854775807"}},
+		{"{-9223372036854775808..9223372036854775807..9223372036854775807}", []string{"-9223372036854775808", "-1", "9223372036854775806"}},
+		{"{1..9999999999999}", []string{"{1..9999999999999}"}},
+		{"{1..99999999999999999999}", []string{"{1..99999999999999999999}"}},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		var got []string
+		for _, w := range ExpandBraces(f.Stmts[0].Cmd.(*CallExpr).Args[0]) {
+			got = append(got, render(w))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ExpandBraces(%q): got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package parser
+
+import "strings"
+
+// SpanKind classifies a span of source for syntax highlighting.
+type SpanKind int
+
+const (
+	SpanCommand  SpanKind = iota // Unquoted word naming the command to run
+	SpanKeyword                  // Reserved word such as 'if' or 'done'
+	SpanAssign                   // NAME= of an assignment
+	SpanString                   // Quoted string or here-document body
+	SpanVariable                 // Parameter expansion such as $HOME
+	SpanSubst                    // Command substitution or arithmetic expansion
+	SpanOperator                 // Control operator such as '|' or '&&'
+	SpanRedirect                 // Redirection operator, with its descriptor number
+	SpanComment                  // Comment
+)
+
+// Span is a classified range of source.
+type Span struct {
+	Kind     SpanKind
+	Pos, End Pos
+}
+
+// Spans splits src into spans for syntax highlighting, in order and
+// without overlaps. Unlike Parse it accepts incomplete and invalid input,
+// classifying what it lexes up to the first error; an unterminated quote
+// runs to the end. Plain arguments are not reported.
+func Spans(src string) []Span {
+	h := &highlighter{p: &Parser{src: src}}
+	h.scan(len(src))
+	return h.spans
+}
+
+// highlighter collects the spans of a source.
+type highlighter struct {
+	p     *Parser
+	spans []Span
+	last  int // End of the last span
+}
+
+// add adds a span, clipped so that spans do not overlap.
+func (h *highlighter) add(kind SpanKind, pos, end Pos) {
+	pos = max(pos, Pos(h.last))
+	end = min(end, Pos(len(h.p.src)))
+	if pos >= end {
+		return
+	}
+	h.spans = append(h.spans, Span{Kind: kind, Pos: pos, End: end})
+	h.last = int(end)
+}
+
+// scan adds the spans of the tokens from the read offset up to end. The
+// word after a control operator or a reserved word such as 'then' is in
+// command position.
+func (h *highlighter) scan(end int) {
+	p := h.p
+	cmdPos := true
+	skipWords := 0 // Words that are names rather than commands, e.g. after 'for'
+	for p.err == nil {
+		start := p.off
+		p.next()
+		h.comments(start, min(int(p.tokPos), end))
+		if int(p.tokPos) >= end && p.word == nil {
+			return
+		}
+		if w := p.word; w != nil {
+			lit := w.Lit()
+			var as *Assign
+			if cmdPos {
+				as = p.assign(w)
+			}
+			switch {
+			case skipWords > 0:
+				skipWords--
+				h.word(w)
+				if skipWords == 0 && (lit == "in" || lit == "do") {
+					// 'for NAME in' and 'case WORD in'
+					h.add(SpanKeyword, w.Pos(), w.End())
+				}
+			case cmdPos && IsReservedWord(lit):
+				h.add(SpanKeyword, w.Pos(), w.End())
+				switch lit {
+				case "for", "case":
+					skipWords, cmdPos = 2, false
+				case "function":
+					skipWords, cmdPos = 1, false
+				case "}", "fi", "done", "esac":
+					cmdPos = false
+				}
+			case as != nil:
+				nameEnd := as.Position + Pos(len(as.Name)) + 1
+				if as.Append {
+					nameEnd++
+				}
+				h.add(SpanAssign, as.Position, nameEnd)
+				if as.Value != nil {
+					h.word(as.Value)
+				}
+			case cmdPos && lit != "":
+				h.add(SpanCommand, w.Pos(), w.End())
+				cmdPos = false
+			default:
+				h.word(w)
+				cmdPos = false
+			}
+			continue
+		}
+
+		if p.tok != tRedirect {
+			skipWords = 0
+		}
+		if int(p.tokPos) < len(p.src) && p.src[p.tokPos] == '\n' {
+			// Here-document bodies are read with the newline, even
+			// when the input ends in one
+			h.add(SpanString, p.tokPos+1, Pos(p.off))
+		}
+		switch p.tok {
+		case tEOF:
+			return
+		case tRedirect:
+			h.add(SpanRedirect, p.tokPos, Pos(p.off))
+			op := p.redirOp
+			// The target is an argument, and the delimiter of a
+			// here-document starts a body after the line
+			start := p.off
+			p.next()
+			h.comments(start, int(p.tokPos))
+			if p.word != nil {
+				h.word(p.word)
+				if op == RdrHeredoc || op == RdrHeredocTabs {
+					p.heredocs = append(p.heredocs, &Redirect{OpPos: p.tokPos, Op: op, Word: p.word})
+				}
+			}
+		case tNewline:
+			cmdPos = true
+		case tDblLParen:
+			// An arithmetic command, lexed as the parser does
+			left := p.tokPos
+			_, right := p.lexArithm(left)
+			h.add(SpanSubst, left, right+2)
+			cmdPos = false
+		default:
+			h.add(SpanOperator, p.tokPos, Pos(p.off))
+			cmdPos = true
+		}
+	}
+}
+
+// comments adds the comments skipped between start and end.
+func (h *highlighter) comments(start, end int) {
+	if start >= end {
+		return
+	}
+	if i := strings.IndexByte(h.p.src[start:end], '#'); i >= 0 {
+		h.add(SpanComment, Pos(start+i), Pos(end))
+	}
+}
+
+// word adds the spans of the quotes and expansions in a word.
+func (h *highlighter) word(w *Word) {
+	for _, part := range w.Parts {
+		h.part(part)
+	}
+}
+
+// part adds the spans of a word part. Double-quoted strings are split
+// around the expansions they hold.
+func (h *highlighter) part(part WordPart) {
+	switch part := part.(type) {
+	case *SglQuoted:
+		h.add(SpanString, part.Pos(), part.End())
+	case *DblQuoted:
+		pos := part.Pos()
+		for _, inner := range part.Parts {
+			if _, ok := inner.(*Lit); ok {
+				continue
+			}
+			h.add(SpanString, pos, inner.Pos())
+			h.part(inner)
+			pos = inner.End()
+		}
+		h.add(SpanString, pos, part.End())
+	case *ParamExp:
+		end := part.End()
+		if end <= part.Pos() {
+			end = Pos(len(h.p.src)) // Unterminated ${
+		}
+		h.add(SpanVariable, part.Pos(), end)
+	case *ArithmExp:
+		h.add(SpanSubst, part.Pos(), part.End())
+	case *CmdSubst:
+		if part.Backquotes {
+			h.add(SpanSubst, part.Pos(), part.End())
+			return
+		}
+		// The commands inside are highlighted like the outer ones. An
+		// unterminated $( leaves the parser failed with Right on the last
+		// byte, and runs to the end.
+		end := int(part.Right)
+		closed := h.p.err == nil || end < len(h.p.src)-1
+		if !closed {
+			end = len(h.p.src)
+		}
+		h.add(SpanSubst, part.Left, part.Left+2)
+		sub := &highlighter{p: &Parser{src: h.p.src, off: int(part.Left) + 2}, last: h.last}
+		sub.scan(end)
+		h.spans = append(h.spans, sub.spans...)
+		h.last = max(h.last, sub.last)
+		if closed {
+			h.add(SpanSubst, part.Right, part.Right+1)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package parser
+
+import "testing"
+
+func TestSpansPrefixes(t *testing.T) {
+	lines := []string{
+		`echo $(ls -l | grep "x") # list`,
+		`a=$(cat <<EOF` + "\n" + `body $HOME` + "\n" + `EOF` + "\n" + `)`,
+		`for i in 1 2; do echo "${i:-none}" >&2; done`,
+		`case $x in a) echo 'a';; esac && ((n += 1))`,
+		"echo `date` $(echo $(pwd)) ${#v}",
+		`f() { 2>/dev/null ls; } ; $(0000`,
+	}
+	for _, line := range lines {
+		for i := 0; i <= len(line); i++ {
+			src := line[:i]
+			last := Pos(0)
+			for _, span := range Spans(src) {
+				if span.Pos < last || span.End <= span.Pos || int(span.End) > len(src) {
+					t.Errorf("Spans(%q): bad span %+v after %d", src, span, last)
+				}
+				last = span.End
+			}
+		}
+	}
+}
@@ -0,0 +1,739 @@
+package parser
+
+import (
+	"strings"
+)
+
+// token identifies the kind of a lexical token.
+type token int
+
+const (
+	tEOF        token = iota
+	tNewline          // \n
+	tWord             // any word; the parsed Word is in Parser.word
+	tSemi             // ;
+	tDblSemi          // ;;
+	tSemiAnd          // ;&
+	tDblSemiAnd       // ;;&
+	tAmp              // &
+	tPipe             // |
+	tAndIf            // &&
+	tOrIf             // ||
+	tLParen           // (
+	tRParen           // )
+	tDblLParen        // (( starting an arithmetic command
+	tRedirect         // any redirection; details are in Parser.redirOp and Parser.redirN
+)
+
+var tokenStrings = map[token]string{
+	tEOF:        "end of input",
+	tNewline:    "newline",
+	tWord:       "word",
+	tSemi:       ";",
+	tDblSemi:    ";;",
+	tSemiAnd:    ";&",
+	tDblSemiAnd: ";;&",
+	tAmp:        "&",
+	tPipe:       "|",
+	tAndIf:      "&&",
+	tOrIf:       "||",
+	tLParen:     "(",
+	tRParen:     ")",
+	tDblLParen:  "((",
+	tRedirect:   "redirection",
+}
+
+func (t token) String() string { return tokenStrings[t] }
+
+// isMeta reports whether b ends an unquoted word.
+func isMeta(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '|', '&', ';', '<', '>', '(', ')':
+		return true
+	}
+	return false
+}
+
+func isDigit(b byte) bool { return b >= '0' && b <= '9' }
+
+// peekByte returns the byte n positions after the read offset, or 0 past the end.
+func (p *Parser) peekByte(n int) byte {
+	if p.off+n < len(p.src) {
+		return p.src[p.off+n]
+	}
+	return 0
+}
+
+// next advances to the next token, storing it in p.tok.
+func (p *Parser) next() {
+	if p.err != nil {
+		p.tok = tEOF
+		return
+	}
+	p.skipBlanks()
+	p.tokPos = Pos(p.off)
+	p.word = nil
+
+	if p.off >= len(p.src) {
+		if len(p.heredocs) > 0 {
+			p.heredocErr(p.heredocs[0])
+		}
+		p.tok = tEOF
+		return
+	}
+
+	switch b := p.src[p.off]; b {
+	case '\n':
+		p.off++
+		p.tok = tNewline
+		p.readHeredocs()
+	case ';':
+		switch {
+		case strings.HasPrefix(p.src[p.off:], ";;&"):
+			p.off += 3
+			p.tok = tDblSemiAnd
+		case p.peekByte(1) == ';':
+			p.off += 2
+			p.tok = tDblSemi
+		case p.peekByte(1) == '&':
+			p.off += 2
+			p.tok = tSemiAnd
+		default:
+			p.off++
+			p.tok = tSemi
+		}
+	case '(':
+		if p.peekByte(1) == '(' {
+			p.off += 2
+			p.tok = tDblLParen
+		} else {
+			p.off++
+			p.tok = tLParen
+		}
+	case ')':
+		p.off++
+		p.tok = tRParen
+	case '|':
+		if p.peekByte(1) == '|' {
+			p.off += 2
+			p.tok = tOrIf
+		} else {
+			p.off++
+			p.tok = tPipe
+		}
+	case '&':
+		switch {
+		case p.peekByte(1) == '&':
+			p.off += 2
+			p.tok = tAndIf
+		case p.peekByte(1) == '>':
+			p.lexRedirect(-1)
+		default:
+			p.off++
+			p.tok = tAmp
+		}
+	case '<', '>':
+		p.lexRedirect(-1)
+	default:
+		// A run of digits directly followed by '<' or '>' is a file descriptor number.
+		i := p.off
+		for i < len(p.src) && isDigit(p.src[i]) {
+			i++
+		}
+		if i > p.off && i < len(p.src) && (p.src[i] == '<' || p.src[i] == '>') {
+			n := 0
+			for _, d := range p.src[p.off:i] {
+				n = n*10 + int(d-'0')
+			}
+			p.off = i
+			p.lexRedirect(n)
+			return
+		}
+		p.tok = tWord
+		p.word = p.lexWord()
+	}
+}
+
+// skipBlanks skips spaces, tabs, line continuations and comments.
+func (p *Parser) skipBlanks() {
+	for p.off < len(p.src) {
+		switch p.src[p.off] {
+		case ' ', '\t', '\r':
+			p.off++
+		case '\\':
+			if p.peekByte(1) != '\n' {
+				return
+			}
+			p.off += 2
+		case '#':
+			for p.off < len(p.src) && p.src[p.off] != '\n' {
+				p.off++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// lexRedirect lexes a redirection operator at the read offset. n is the
+// explicit file descriptor that preceded it, or -1.
+func (p *Parser) lexRedirect(n int) {
+	p.tok = tRedirect
+	p.redirN = n
+	rest := p.src[p.off:]
+	switch {
+	case strings.HasPrefix(rest, "&>>"):
+		p.redirOp, p.off = RdrAllAppend, p.off+3
+	case strings.HasPrefix(rest, "&>"):
+		p.redirOp, p.off = RdrAll, p.off+2
+	case strings.HasPrefix(rest, ">>"):
+		p.redirOp, p.off = RdrAppend, p.off+2
+	case strings.HasPrefix(rest, ">&"):
+		p.redirOp, p.off = RdrDupOut, p.off+2
+	case strings.HasPrefix(rest, ">|"):
+		p.redirOp, p.off = RdrClobber, p.off+2
+	case strings.HasPrefix(rest, "<<<"):
+		p.redirOp, p.off = RdrHerestring, p.off+3
+	case strings.HasPrefix(rest, "<<-"):
+		p.redirOp, p.off = RdrHeredocTabs, p.off+3
+	case strings.HasPrefix(rest, "<<"):
+		p.redirOp, p.off = RdrHeredoc, p.off+2
+	case strings.HasPrefix(rest, "<&"):
+		p.redirOp, p.off = RdrDupIn, p.off+2
+	case strings.HasPrefix(rest, "<>"):
+		p.redirOp, p.off = RdrInOut, p.off+2
+	case strings.HasPrefix(rest, ">"):
+		p.redirOp, p.off = RdrOut, p.off+1
+	default:
+		p.redirOp, p.off = RdrIn, p.off+1
+	}
+}
+
+// readHeredocs reads the bodies of the here-documents started on the line
+// that just ended, in order. Each body runs up to a line holding only its
+// delimiter, after leading tabs for '<<-'.
+func (p *Parser) readHeredocs() {
+	pending := p.heredocs
+	p.heredocs = nil
+	for _, r := range pending {
+		delim, quoted := HeredocDelim(r.Word)
+		stripTabs := r.Op == RdrHeredocTabs
+		start := p.off
+		for {
+			if p.off >= len(p.src) {
+				p.heredocErr(r)
+				return
+			}
+			end := strings.IndexByte(p.src[p.off:], '\n')
+			if end < 0 {
+				end = len(p.src)
+			} else {
+				end += p.off
+			}
+			line := p.src[p.off:end]
+			if stripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == delim {
+				r.Hdoc = p.lexHeredocBody(start, p.off, !quoted, stripTabs)
+				p.off = min(end+1, len(p.src))
+				break
+			}
+			p.off = min(end+1, len(p.src))
+		}
+		if p.err != nil {
+			return
+		}
+	}
+}
+
+// heredocErr records that the input ended before the body of the
+// here-document r.
+func (p *Parser) heredocErr(r *Redirect) {
+	if p.err != nil {
+		return
+	}
+	delim, _ := HeredocDelim(r.Word)
+	p.incompleteErr(r.OpPos, "unterminated here-document (wanted '%s')", delim)
+	if perr, ok := p.err.(*ParseError); ok {
+		perr.Heredoc = true
+	}
+}
+
+// HeredocDelim returns the delimiter a here-document ends with, which is
+// its word with quotes removed, and whether any part of it was quoted.
+// The body of a here-document with a quoted delimiter is not expanded.
+func HeredocDelim(w *Word) (string, bool) {
+	var sb strings.Builder
+	quoted := false
+	var add func(parts []WordPart)
+	add = func(parts []WordPart) {
+		for _, part := range parts {
+			switch part := part.(type) {
+			case *Lit:
+				if strings.Contains(part.Value, `\`) {
+					quoted = true
+				}
+				for i := 0; i < len(part.Value); i++ {
+					if part.Value[i] == '\\' && i+1 < len(part.Value) {
+						i++
+					}
+					sb.WriteByte(part.Value[i])
+				}
+			case *SglQuoted:
+				quoted = true
+				sb.WriteString(part.Value)
+			case *DblQuoted:
+				quoted = true
+				add(part.Parts)
+			case *ParamExp:
+				if part.Short {
+					sb.WriteString("$" + part.Param)
+				}
+			}
+		}
+	}
+	add(w.Parts)
+	return sb.String(), quoted
+}
+
+// lexHeredocBody lexes the body of a here-document between the offsets
+// start and end, dropping the leading tabs of each line with stripTabs.
+// Unless expand is set the text is only literals.
+func (p *Parser) lexHeredocBody(start, end int, expand, stripTabs bool) *Word {
+	var parts []WordPart
+	litStart := -1
+	flushLitAt := func(end int) {
+		if litStart >= 0 && end > litStart {
+			parts = append(parts, &Lit{ValuePos: Pos(litStart), Value: p.src[litStart:end]})
+		}
+		litStart = -1
+	}
+
+	p.off = start
+	for p.off < end && p.err == nil {
+		if stripTabs && p.src[p.off] == '\t' && (p.off == start || p.src[p.off-1] == '\n') {
+			flushLitAt(p.off)
+			for p.off < end && p.src[p.off] == '\t' {
+				p.off++
+			}
+			continue
+		}
+		switch b := p.src[p.off]; {
+		case expand && b == '$':
+			dollar := p.off
+			if part := p.lexDollar(); part != nil {
+				flushLitAt(dollar)
+				parts = append(parts, part)
+				continue
+			}
+			if litStart < 0 {
+				litStart = p.off
+			}
+			p.off++
+		case expand && b == '`':
+			flushLitAt(p.off)
+			parts = append(parts, p.lexBackquote())
+		case expand && b == '\\':
+			if litStart < 0 {
+				litStart = p.off
+			}
+			p.off = min(p.off+2, end)
+		default:
+			if litStart < 0 {
+				litStart = p.off
+			}
+			p.off++
+		}
+	}
+	flushLitAt(min(p.off, end))
+	if len(parts) == 0 {
+		return nil
+	}
+	return &Word{Parts: parts}
+}
+
+// lexWord lexes a word starting at the read offset.
+func (p *Parser) lexWord() *Word {
+	return &Word{Parts: p.lexWordParts(isMeta)}
+}
+
+// lexWordParts lexes word parts until an unquoted byte for which stop
+// returns true, or the end of input.
+func (p *Parser) lexWordParts(stop func(byte) bool) []WordPart {
+	var parts []WordPart
+	litStart := -1
+	flushLitAt := func(end int) {
+		if litStart >= 0 {
+			parts = append(parts, &Lit{ValuePos: Pos(litStart), Value: p.src[litStart:end]})
+			litStart = -1
+		}
+	}
+	flushLit := func() { flushLitAt(p.off) }
+
+	for p.off < len(p.src) && p.err == nil {
+		b := p.src[p.off]
+		if stop(b) {
+			break
+		}
+		switch b {
+		case '\'':
+			flushLit()
+			parts = append(parts, p.lexSglQuoted())
+		case '"':
+			flushLit()
+			parts = append(parts, p.lexDblQuoted())
+		case '`':
+			flushLit()
+			parts = append(parts, p.lexBackquote())
+		case '$':
+			dollar := p.off
+			if part := p.lexDollar(); part != nil {
+				flushLitAt(dollar)
+				parts = append(parts, part)
+				continue
+			}
+			if litStart < 0 {
+				litStart = p.off
+			}
+			p.off++
+		case '\\':
+			if litStart < 0 {
+				litStart = p.off
+			}
+			if p.off+1 >= len(p.src) {
+				p.off++
+				p.incompleteErr(Pos(p.off-1), "unexpected end of input after '\\'")
+				break
+			}
+			p.off += 2
+		default:
+			if litStart < 0 {
+				litStart = p.off
+			}
+			p.off++
+		}
+	}
+	flushLit()
+	return parts
+}
+
+// lexSglQuoted lexes a '...' string; the read offset is at the opening quote.
+func (p *Parser) lexSglQuoted() *SglQuoted {
+	left := p.off
+	end := strings.IndexByte(p.src[left+1:], '\'')
+	if end < 0 {
+		p.off = len(p.src)
+		p.incompleteErr(Pos(left), "unterminated single quote")
+		return &SglQuoted{Left: Pos(left), Right: Pos(p.off - 1), Value: p.src[left+1:]}
+	}
+	right := left + 1 + end
+	p.off = right + 1
+	return &SglQuoted{Left: Pos(left), Right: Pos(right), Value: p.src[left+1 : right]}
+}
+
+// lexDblQuoted lexes a "..." string; the read offset is at the opening quote.
+func (p *Parser) lexDblQuoted() *DblQuoted {
+	q := &DblQuoted{Left: Pos(p.off)}
+	p.off++
+	litStart := -1
+	flushLitAt := func(end int) {
+		if litStart >= 0 {
+			q.Parts = append(q.Parts, &Lit{ValuePos: Pos(litStart), Value: p.src[litStart:end]})
+			litStart = -1
+		}
+	}
+	flushLit := func() { flushLitAt(p.off) }
+
+	for p.off < len(p.src) {
+		switch p.src[p.off] {
+		case '"':
+			flushLit()
+			q.Right = Pos(p.off)
+			p.off++
+			return q
+		case '`':
+			flushLit()
+			q.Parts = append(q.Parts, p.lexBackquote())
+		case '$':
+			dollar := p.off
+			if part := p.lexDollar(); part != nil {
+				flushLitAt(dollar)
+				q.Parts = append(q.Parts, part)
+				continue
+			}
+			if litStart < 0 {
+				litStart = p.off
+			}
+			p.off++
+		case '\\':
+			if litStart < 0 {
+				litStart = p.off
+			}
+			p.off += 2
+		default:
+			if litStart < 0 {
+				litStart = p.off
+			}
+			p.off++
+		}
+	}
+	if p.off > len(p.src) {
+		p.off = len(p.src)
+	}
+	flushLit()
+	q.Right = Pos(p.off - 1)
+	p.incompleteErr(q.Left, "unterminated double quote")
+	return q
+}
+
+// lexDollar lexes the expansion starting with the '$' at the read offset
+// and advances past it. It returns nil without advancing if the '$' is literal.
+func (p *Parser) lexDollar() WordPart {
+	dollar := p.off
+	b := p.peekByte(1)
+	switch {
+	case b == '{':
+		return p.lexParamBraces()
+	case b == '(' && p.peekByte(2) == '(':
+		a := &ArithmExp{Left: Pos(dollar)}
+		p.off += 3
+		a.X, a.Right = p.lexArithm(a.Left)
+		return a
+	case b == '(':
+		return p.lexCmdSubst()
+	case isSpecialParam(b) || isDigit(b):
+		p.off += 2
+		return &ParamExp{Dollar: Pos(dollar), Short: true, Param: string(b)}
+	case isNameStart(b):
+		end := dollar + 1
+		for end < len(p.src) && isNameByte(p.src[end]) {
+			end++
+		}
+		p.off = end
+		return &ParamExp{Dollar: Pos(dollar), Short: true, Param: p.src[dollar+1 : end]}
+	}
+	return nil
+}
+
+// lexParamBraces lexes a ${...} expansion; the read offset is at the '$'.
+func (p *Parser) lexParamBraces() *ParamExp {
+	pe := &ParamExp{Dollar: Pos(p.off)}
+	p.off += 2
+
+	// ${#NAME} is the length of NAME, but ${#} is the number of arguments
+	if p.peekByte(0) == '#' && p.peekByte(1) != '}' && p.peekByte(1) != 0 {
+		pe.Length = true
+		p.off++
+	}
+
+	switch b := p.peekByte(0); {
+	case isNameStart(b):
+		start := p.off
+		for p.off < len(p.src) && isNameByte(p.src[p.off]) {
+			p.off++
+		}
+		pe.Param = p.src[start:p.off]
+	case isDigit(b):
+		start := p.off
+		for p.off < len(p.src) && isDigit(p.src[p.off]) {
+			p.off++
+		}
+		pe.Param = p.src[start:p.off]
+	case isSpecialParam(b):
+		pe.Param = string(b)
+		p.off++
+	case b == 0:
+		p.incompleteErr(pe.Dollar, "unterminated '${'")
+		return pe
+	default:
+		p.errAt(pe.Dollar, false, "bad substitution")
+		return pe
+	}
+
+	if !pe.Length {
+		p.lexParamOperator(pe)
+	}
+	if p.err != nil {
+		return pe
+	}
+	if p.off >= len(p.src) {
+		p.incompleteErr(pe.Dollar, "unterminated '${'")
+		return pe
+	}
+	if p.src[p.off] != '}' {
+		p.errAt(Pos(p.off), false, "bad substitution")
+		return pe
+	}
+	pe.Rbrace = Pos(p.off)
+	p.off++
+	return pe
+}
+
+// lexCmdSubst lexes a $(...) command substitution; the read offset is at
+// the '$'. The statements are parsed by a nested parser over the same source.
+func (p *Parser) lexCmdSubst() *CmdSubst {
+	cs := &CmdSubst{Left: Pos(p.off)}
+	sub := &Parser{src: p.src, off: p.off + 2}
+	sub.next()
+	cs.Stmts = sub.stmtList()
+	if sub.err == nil {
+		switch sub.tok {
+		case tRParen:
+		case tEOF:
+			sub.incompleteErr(cs.Left, "unterminated '$('")
+		default:
+			sub.unexpected()
+		}
+	}
+	if sub.err != nil {
+		p.off = len(p.src)
+		cs.Right = Pos(p.off - 1)
+		if p.err == nil {
+			p.err = sub.err
+		}
+		return cs
+	}
+	cs.Right = sub.tokPos
+	p.off = int(sub.tokPos) + 1
+	return cs
+}
+
+// lexArithm lexes an arithmetic expression up to the closing "))" and
+// consumes it. It returns the expression, nil if empty, and the position
+// of the "))".
+func (p *Parser) lexArithm(left Pos) (*Word, Pos) {
+	x := p.lexArithmWord(false)
+	if p.err != nil {
+		return nil, Pos(p.off)
+	}
+	if p.off >= len(p.src) {
+		p.incompleteErr(left, "unterminated '(('")
+		return nil, Pos(p.off)
+	}
+	if !strings.HasPrefix(p.src[p.off:], "))") {
+		p.errAt(Pos(p.off), false, "expected '))'")
+		return nil, Pos(p.off)
+	}
+	right := Pos(p.off)
+	p.off += 2
+	return x, right
+}
+
+// lexArithmWord lexes arithmetic text up to an unbalanced ')' or, with
+// semi, a ';' outside parentheses. It returns nil for empty text.
+func (p *Parser) lexArithmWord(semi bool) *Word {
+	depth := 0
+	parts := p.lexWordParts(func(b byte) bool {
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return true
+			}
+			depth--
+		case ';':
+			return semi && depth == 0
+		}
+		return false
+	})
+	if len(parts) == 0 {
+		return nil
+	}
+	return &Word{Parts: parts}
+}
+
+// lexBackquote lexes a `...` command substitution; the read offset is at
+// the opening backquote. Inside, a backslash only escapes '`', '$' and '\'.
+func (p *Parser) lexBackquote() *CmdSubst {
+	cs := &CmdSubst{Left: Pos(p.off), Backquotes: true}
+	var sb strings.Builder
+	i := p.off + 1
+	for ; i < len(p.src) && p.src[i] != '`'; i++ {
+		if p.src[i] == '\\' && i+1 < len(p.src) && strings.IndexByte("`$\\", p.src[i+1]) >= 0 {
+			i++
+		}
+		sb.WriteByte(p.src[i])
+	}
+	if i >= len(p.src) {
+		p.off = len(p.src)
+		cs.Right = Pos(p.off - 1)
+		p.incompleteErr(cs.Left, "unterminated '`'")
+		return cs
+	}
+	cs.Right = Pos(i)
+	p.off = i + 1
+
+	f, err := Parse(sb.String())
+	if err != nil {
+		perr := err.(*ParseError)
+		p.errAt(cs.Left, perr.Incomplete, "in '`': %s", perr.Msg)
+		return cs
+	}
+	cs.Stmts = f.Stmts
+	return cs
+}
+
+// paramOperators maps the operators allowed after a name in ${...}, longest first.
+var paramOperators = []struct {
+	text string
+	op   ParExpOperator
+}{
+	{":-", DefaultUnsetOrNull}, {":=", AssignUnsetOrNull}, {":?", ErrorUnsetOrNull}, {":+", AlternateUnsetOrNull},
+	{"##", RemLargePrefix}, {"%%", RemLargeSuffix},
+	{"-", DefaultUnset}, {"=", AssignUnset}, {"?", ErrorUnset}, {"+", AlternateUnset},
+	{"#", RemSmallPrefix}, {"%", RemSmallSuffix},
+}
+
+// lexParamOperator lexes the optional operator and word of a ${...} expansion.
+func (p *Parser) lexParamOperator(pe *ParamExp) {
+	rest := p.src[p.off:]
+	if strings.HasPrefix(rest, "/") {
+		pe.Repl = &Replace{}
+		p.off++
+		switch p.peekByte(0) {
+		case '/':
+			pe.Repl.All = true
+			p.off++
+		case '#', '%':
+			pe.Repl.Anchor = p.peekByte(0)
+			p.off++
+		}
+		pe.Repl.Orig = p.lexParamWord(func(b byte) bool { return b == '/' || b == '}' })
+		if p.peekByte(0) == '/' {
+			p.off++
+			pe.Repl.With = p.lexParamWord(func(b byte) bool { return b == '}' })
+		}
+		return
+	}
+	for _, po := range paramOperators {
+		if strings.HasPrefix(rest, po.text) {
+			p.off += len(po.text)
+			pe.Exp = &Expansion{Op: po.op}
+			pe.Exp.Word = p.lexParamWord(func(b byte) bool { return b == '}' })
+			return
+		}
+	}
+}
+
+// lexParamWord lexes the word inside ${...}, where blanks and operators
+// are literal. It returns nil for an empty word.
+func (p *Parser) lexParamWord(stop func(byte) bool) *Word {
+	parts := p.lexWordParts(stop)
+	if len(parts) == 0 {
+		return nil
+	}
+	return &Word{Parts: parts}
+}
+
+// isSpecialParam reports whether b names a special parameter like $? or $#.
+func isSpecialParam(b byte) bool {
+	return b != 0 && strings.IndexByte("?$#@*!-", b) >= 0
+}
+
+func isNameStart(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isNameByte(b byte) bool { return isNameStart(b) || isDigit(b) }
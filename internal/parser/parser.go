@@ -0,0 +1,626 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parser is a recursive-descent parser for dush command lines.
+// The lexer is embedded in the parser because tokenizing a shell word
+// depends on the parser's state.
+type Parser struct {
+	src string
+	off int // Read offset into src
+
+	tok     token // Current lookahead token
+	tokPos  Pos
+	word    *Word         // Set when tok == tWord
+	redirOp RedirOperator // Set when tok == tRedirect
+	redirN  int           // Set when tok == tRedirect
+
+	heredocs []*Redirect // Here-documents whose body starts after the next newline
+
+	err error
+}
+
+// ParseError describes a syntax error.
+type ParseError struct {
+	Pos  Pos
+	Line int
+	Col  int
+	Msg  string
+	// Incomplete is true when the error was caused by the input ending too
+	// early, e.g. an unterminated quote or a trailing '|'. Reading more input
+	// may make it valid.
+	Incomplete bool
+	// Heredoc is true when the input ended inside the body of a
+	// here-document, so the lines that follow belong to it.
+	Heredoc bool
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// Parse parses src into a File.
+func Parse(src string) (*File, error) {
+	p := &Parser{src: src}
+	p.next()
+	f := &File{Stmts: p.stmtList()}
+	if p.err == nil && p.tok != tEOF {
+		p.unexpected()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return f, nil
+}
+
+// errAt records a syntax error at pos unless one was already recorded.
+func (p *Parser) errAt(pos Pos, incomplete bool, format string, a ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	line := 1 + strings.Count(p.src[:pos], "\n")
+	col := int(pos) - strings.LastIndexByte(p.src[:pos], '\n')
+	p.err = &ParseError{Pos: pos, Line: line, Col: col, Msg: fmt.Sprintf(format, a...), Incomplete: incomplete}
+	p.tok = tEOF
+}
+
+// incompleteErr records an error caused by the input ending too early.
+func (p *Parser) incompleteErr(pos Pos, format string, a ...interface{}) {
+	p.errAt(pos, true, format, a...)
+}
+
+// unexpected records an error about the current token.
+func (p *Parser) unexpected() {
+	switch p.tok {
+	case tEOF:
+		p.incompleteErr(p.tokPos, "unexpected end of input")
+	case tWord:
+		p.errAt(p.tokPos, false, "unexpected word '%s'", p.src[p.word.Pos():p.word.End()])
+	case tRedirect:
+		p.errAt(p.tokPos, false, "unexpected token '%s'", p.redirOp)
+	default:
+		p.errAt(p.tokPos, false, "unexpected token '%s'", p.tok)
+	}
+}
+
+// skipNewlines skips any newline tokens, e.g. after '|' or '&&'.
+func (p *Parser) skipNewlines() {
+	for p.tok == tNewline {
+		p.next()
+	}
+}
+
+// stmtList parses statements until a token that cannot start a command.
+func (p *Parser) stmtList() []*Stmt {
+	var stmts []*Stmt
+	for p.err == nil {
+		p.skipNewlines()
+		if !p.startsCommand() {
+			break
+		}
+		stmt := &Stmt{Position: p.tokPos}
+		stmt.Cmd = p.andOr()
+		if p.err != nil {
+			break
+		}
+		stmt.EndPos = stmt.Cmd.End()
+		stmt.Source = p.src[stmt.Position:stmt.EndPos]
+		switch p.tok {
+		case tAmp:
+			stmt.Background = true
+			stmt.EndPos = p.tokPos + 1
+			p.next()
+		case tSemi:
+			stmt.EndPos = p.tokPos + 1
+			p.next()
+		case tNewline, tEOF:
+		default:
+			stmts = append(stmts, stmt)
+			return stmts
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// startsCommand reports whether the current token can begin a command.
+// Reserved words that end a list, like 'then' or 'done', cannot.
+func (p *Parser) startsCommand() bool {
+	switch p.tok {
+	case tWord:
+		switch p.word.Lit() {
+		case "then", "elif", "else", "fi", "do", "done", "esac", "}":
+			return false
+		}
+		return true
+	case tRedirect, tDblLParen:
+		return true
+	}
+	return false
+}
+
+// gotReserved reports whether the current token is the reserved word s.
+// Reserved words are only recognized unquoted.
+func (p *Parser) gotReserved(s string) bool {
+	return p.tok == tWord && p.word.Lit() == s
+}
+
+// expectReserved consumes the reserved word s and returns its position.
+func (p *Parser) expectReserved(s string) Pos {
+	pos := p.tokPos
+	switch {
+	case p.gotReserved(s):
+		p.next()
+	case p.tok == tEOF:
+		p.incompleteErr(pos, "expected '%s'", s)
+	default:
+		p.unexpected()
+	}
+	return pos
+}
+
+// list parses a statement list that must not be empty, like the body of a loop.
+func (p *Parser) list() []*Stmt {
+	stmts := p.stmtList()
+	if p.err == nil && len(stmts) == 0 {
+		p.unexpected()
+	}
+	return stmts
+}
+
+// andOr parses pipelines joined by '&&' and '||'.
+func (p *Parser) andOr() Command {
+	x := p.pipeline()
+	for p.err == nil && (p.tok == tAndIf || p.tok == tOrIf) {
+		b := &BinaryCmd{OpPos: p.tokPos, Op: AndIf, X: x}
+		if p.tok == tOrIf {
+			b.Op = OrIf
+		}
+		p.next()
+		p.skipNewlines()
+		b.Y = p.pipeline()
+		x = b
+	}
+	return x
+}
+
+// pipeline parses commands joined by '|', optionally negated with '!'.
+// A pipeline with a single command and no '!' is returned as the bare command.
+func (p *Parser) pipeline() Command {
+	pl := &Pipeline{Position: p.tokPos}
+	if p.tok == tWord && p.word.Lit() == "!" {
+		pl.Negated = true
+		p.next()
+	}
+	pl.Cmds = append(pl.Cmds, p.command())
+	for p.err == nil && p.tok == tPipe {
+		p.next()
+		p.skipNewlines()
+		pl.Cmds = append(pl.Cmds, p.command())
+	}
+	if p.err != nil {
+		return pl
+	}
+	if len(pl.Cmds) == 1 && !pl.Negated {
+		return pl.Cmds[0]
+	}
+	return pl
+}
+
+// command parses a single command.
+func (p *Parser) command() Command {
+	if !p.startsCommand() {
+		p.unexpected()
+		return &CallExpr{Position: p.tokPos, EndPos: p.tokPos}
+	}
+	switch p.tok {
+	case tDblLParen:
+		return p.arithmCmd()
+	case tRedirect:
+		// A leading redirection starts a simple command, as in '2>&1 ls'
+		return p.callExpr()
+	}
+	switch name := p.word.Lit(); name {
+	case "if":
+		return p.ifClause()
+	case "while", "until":
+		return p.whileClause()
+	case "for":
+		return p.forClause()
+	case "case":
+		return p.caseClause()
+	case "{":
+		return p.block()
+	case "function":
+		return p.funcDecl(true)
+	default:
+		if name != "" && !IsReservedWord(name) && p.atFuncParens() {
+			return p.funcDecl(false)
+		}
+	}
+	return p.callExpr()
+}
+
+// reservedWords are the words with a special meaning at the start of a command.
+var reservedWords = []string{
+	"!", "{", "}", "case", "do", "done", "elif", "else", "esac", "fi",
+	"for", "function", "if", "in", "then", "until", "while",
+}
+
+// IsReservedWord reports whether s is a reserved word like 'if' or 'done'.
+func IsReservedWord(s string) bool {
+	for _, w := range reservedWords {
+		if w == s {
+			return true
+		}
+	}
+	return false
+}
+
+// block parses a { list; } group.
+func (p *Parser) block() *Block {
+	b := &Block{Lbrace: p.tokPos}
+	p.next()
+	b.Stmts = p.list()
+	b.Rbrace = p.expectReserved("}")
+	b.Redirs = p.redirects()
+	return b
+}
+
+// atFuncParens reports whether the current word is followed by "()",
+// making it the name in a function definition.
+func (p *Parser) atFuncParens() bool {
+	i := p.off
+	skip := func() {
+		for i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t') {
+			i++
+		}
+	}
+	skip()
+	if i >= len(p.src) || p.src[i] != '(' {
+		return false
+	}
+	i++
+	skip()
+	return i < len(p.src) && p.src[i] == ')'
+}
+
+// funcDecl parses a function definition. With keyword, the current token
+// is 'function' and the parentheses after the name are optional.
+func (p *Parser) funcDecl(keyword bool) *FuncDecl {
+	fd := &FuncDecl{Position: p.tokPos}
+	if keyword {
+		p.next()
+		if p.tok != tWord || p.word.Lit() == "" {
+			p.unexpected()
+			return fd
+		}
+	}
+	fd.Name = p.word.Lit()
+	if strings.Contains(fd.Name, "=") {
+		p.errAt(p.tokPos, false, "invalid function name '%s'", fd.Name)
+		return fd
+	}
+	p.next()
+	if p.tok == tLParen {
+		p.next()
+		if p.tok != tRParen {
+			p.unexpected()
+			return fd
+		}
+		p.next()
+	}
+	p.skipNewlines()
+
+	bodyPos := p.tokPos
+	switch {
+	case p.tok == tDblLParen:
+	case p.tok == tWord && IsReservedWord(p.word.Lit()):
+	case p.tok == tEOF:
+		p.incompleteErr(bodyPos, "expected function body")
+		return fd
+	default:
+		p.errAt(bodyPos, false, "function body must be a compound command")
+		return fd
+	}
+	fd.Body = p.command()
+	if _, ok := fd.Body.(*CallExpr); ok && p.err == nil {
+		p.errAt(bodyPos, false, "function body must be a compound command")
+	}
+	if p.err == nil {
+		fd.Source = p.src[fd.Position:fd.End()]
+	}
+	return fd
+}
+
+// arithmCmd parses a ((...)) command; the "((" has been lexed.
+func (p *Parser) arithmCmd() *ArithmCmd {
+	a := &ArithmCmd{Left: p.tokPos}
+	a.X, a.Right = p.lexArithm(a.Left)
+	p.next()
+	return a
+}
+
+// callExpr parses a simple command.
+func (p *Parser) callExpr() *CallExpr {
+	ce := &CallExpr{Position: p.tokPos}
+	for p.err == nil {
+		switch p.tok {
+		case tWord:
+			if len(ce.Args) == 0 {
+				if as := p.assign(p.word); as != nil {
+					ce.Assigns = append(ce.Assigns, as)
+					ce.EndPos = as.End()
+					p.next()
+					continue
+				}
+			}
+			ce.Args = append(ce.Args, p.word)
+			ce.EndPos = p.word.End()
+			p.next()
+		case tRedirect:
+			r := p.redirect()
+			ce.Redirs = append(ce.Redirs, r)
+			ce.EndPos = r.End()
+		default:
+			return ce
+		}
+	}
+	return ce
+}
+
+// ifClause parses an if command.
+func (p *Parser) ifClause() *IfClause {
+	ic := &IfClause{Position: p.tokPos}
+	p.ifBranches(ic)
+	fi := p.expectReserved("fi")
+	for c := ic; c != nil; c = c.Else {
+		c.FiPos = fi
+	}
+	ic.Redirs = p.redirects()
+	return ic
+}
+
+// ifBranches parses the condition and branches following an 'if' or 'elif'.
+func (p *Parser) ifBranches(ic *IfClause) {
+	p.next()
+	ic.Cond = p.list()
+	p.expectReserved("then")
+	ic.Then = p.list()
+	switch {
+	case p.gotReserved("elif"):
+		ic.Else = &IfClause{Position: p.tokPos}
+		p.ifBranches(ic.Else)
+	case p.gotReserved("else"):
+		ic.Else = &IfClause{Position: p.tokPos}
+		p.next()
+		ic.Else.Then = p.list()
+	}
+}
+
+// whileClause parses a while or until loop.
+func (p *Parser) whileClause() *WhileClause {
+	wc := &WhileClause{WhilePos: p.tokPos, Until: p.word.Lit() == "until"}
+	p.next()
+	wc.Cond = p.list()
+	wc.Do, wc.DonePos = p.loopBody()
+	wc.Redirs = p.redirects()
+	return wc
+}
+
+// forClause parses a for loop over words or a C-style for loop.
+func (p *Parser) forClause() Command {
+	forPos := p.tokPos
+	p.next()
+	if p.tok == tDblLParen {
+		return p.arithmForClause(forPos)
+	}
+
+	fc := &ForClause{ForPos: forPos}
+	if p.tok != tWord || !IsValidName(p.word.Lit()) {
+		if p.tok == tWord {
+			p.errAt(p.tokPos, false, "invalid for loop variable '%s'", p.src[p.word.Pos():p.word.End()])
+		} else {
+			p.unexpected()
+		}
+		return fc
+	}
+	fc.Name = p.word.Lit()
+	p.next()
+	if p.tok == tSemi {
+		p.next()
+	}
+	p.skipNewlines()
+	if p.gotReserved("in") {
+		fc.HasIn = true
+		p.next()
+		for p.tok == tWord {
+			fc.Items = append(fc.Items, p.word)
+			p.next()
+		}
+		switch p.tok {
+		case tSemi, tNewline:
+			p.next()
+		default:
+			p.unexpected()
+		}
+		p.skipNewlines()
+	}
+	fc.Do, fc.DonePos = p.loopBody()
+	fc.Redirs = p.redirects()
+	return fc
+}
+
+// arithmForClause parses the rest of a C-style for loop; the "((" has been lexed.
+func (p *Parser) arithmForClause(forPos Pos) *ArithmForClause {
+	fc := &ArithmForClause{ForPos: forPos}
+	left := p.tokPos
+	for i, x := range []**Word{&fc.Init, &fc.Cond} {
+		*x = p.lexArithmWord(true)
+		if p.err != nil {
+			return fc
+		}
+		if p.off >= len(p.src) {
+			p.incompleteErr(left, "unterminated '(('")
+			return fc
+		}
+		if p.src[p.off] != ';' {
+			p.errAt(Pos(p.off), false, "expected ';' in arithmetic for loop (expression %d)", i+1)
+			return fc
+		}
+		p.off++
+	}
+	fc.Post, _ = p.lexArithm(left)
+	p.next()
+	if p.tok == tSemi {
+		p.next()
+	}
+	p.skipNewlines()
+	fc.Do, fc.DonePos = p.loopBody()
+	fc.Redirs = p.redirects()
+	return fc
+}
+
+// loopBody parses "do list done", returning the list and the position of 'done'.
+func (p *Parser) loopBody() ([]*Stmt, Pos) {
+	p.expectReserved("do")
+	body := p.list()
+	return body, p.expectReserved("done")
+}
+
+// caseClause parses a case command.
+func (p *Parser) caseClause() *CaseClause {
+	cc := &CaseClause{CasePos: p.tokPos}
+	p.next()
+	if p.tok != tWord {
+		p.unexpected()
+		return cc
+	}
+	cc.Word = p.word
+	p.next()
+	p.skipNewlines()
+	p.expectReserved("in")
+	for p.err == nil {
+		p.skipNewlines()
+		if p.gotReserved("esac") {
+			break
+		}
+		item := &CaseItem{}
+		if p.tok == tLParen {
+			p.next()
+		}
+		for p.err == nil {
+			if p.tok != tWord {
+				p.unexpected()
+				return cc
+			}
+			item.Patterns = append(item.Patterns, p.word)
+			p.next()
+			if p.tok != tPipe {
+				break
+			}
+			p.next()
+		}
+		if p.tok != tRParen {
+			p.unexpected()
+			return cc
+		}
+		p.next()
+		item.Stmts = p.stmtList()
+		cc.Items = append(cc.Items, item)
+
+		switch p.tok {
+		case tDblSemi:
+			item.Op = CaseBreak
+		case tSemiAnd:
+			item.Op = CaseFallthrough
+		case tDblSemiAnd:
+			item.Op = CaseResume
+		default:
+			// The last item does not need a terminator
+			p.skipNewlines()
+			if !p.gotReserved("esac") && p.err == nil {
+				p.unexpected()
+			}
+			continue
+		}
+		p.next()
+	}
+	cc.EsacPos = p.expectReserved("esac")
+	cc.Redirs = p.redirects()
+	return cc
+}
+
+// redirects parses the redirections following a compound command.
+func (p *Parser) redirects() []*Redirect {
+	var redirs []*Redirect
+	for p.err == nil && p.tok == tRedirect {
+		redirs = append(redirs, p.redirect())
+	}
+	return redirs
+}
+
+// redirect parses a redirection operator and its target word.
+func (p *Parser) redirect() *Redirect {
+	r := &Redirect{OpPos: p.tokPos, Op: p.redirOp, N: p.redirN}
+	p.next()
+	if p.tok != tWord {
+		p.unexpected()
+		r.Word = &Word{Parts: []WordPart{&Lit{ValuePos: p.tokPos}}}
+		return r
+	}
+	r.Word = p.word
+	if r.Op == RdrHeredoc || r.Op == RdrHeredocTabs {
+		p.heredocs = append(p.heredocs, r)
+	}
+	p.next()
+	return r
+}
+
+// assign returns the assignment w represents, or nil if it is not one.
+func (p *Parser) assign(w *Word) *Assign {
+	lit, ok := w.Parts[0].(*Lit)
+	if !ok {
+		return nil
+	}
+	eq := strings.IndexByte(lit.Value, '=')
+	if eq <= 0 {
+		return nil
+	}
+	as := &Assign{Position: lit.ValuePos, Name: lit.Value[:eq]}
+	if strings.HasSuffix(as.Name, "+") {
+		as.Name = as.Name[:len(as.Name)-1]
+		as.Append = true
+	}
+	if !IsValidName(as.Name) {
+		return nil
+	}
+
+	// The value is whatever follows '=', keeping the remaining parts intact.
+	var parts []WordPart
+	if rest := lit.Value[eq+1:]; rest != "" {
+		parts = append(parts, &Lit{ValuePos: lit.ValuePos + Pos(eq+1), Value: rest})
+	}
+	parts = append(parts, w.Parts[1:]...)
+	if len(parts) > 0 {
+		as.Value = &Word{Parts: parts}
+	}
+	return as
+}
+
+// IsValidName reports whether s is a valid shell variable name.
+func IsValidName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
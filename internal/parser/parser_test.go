@@ -0,0 +1,377 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseLeadingRedirect(t *testing.T) {
+	tests := []struct {
+		src    string
+		args   []string
+		redirs int
+	}{
+		{"2>/dev/null ls", []string{"ls"}, 1},
+		{"<in cat", []string{"cat"}, 1},
+		{">f echo hi", []string{"echo", "hi"}, 1},
+		{"2>&1 >out cmd arg", []string{"cmd", "arg"}, 2},
+		{">f", nil, 1},
+		{"<in if", []string{"if"}, 1},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if len(f.Stmts) != 1 {
+			t.Errorf("Parse(%q): got %d statements, want 1", tt.src, len(f.Stmts))
+			continue
+		}
+		call, ok := f.Stmts[0].Cmd.(*CallExpr)
+		if !ok {
+			t.Errorf("Parse(%q): got %T, want *CallExpr", tt.src, f.Stmts[0].Cmd)
+			continue
+		}
+		var args []string
+		for _, w := range call.Args {
+			args = append(args, w.Lit())
+		}
+		if len(args) != len(tt.args) {
+			t.Errorf("Parse(%q): got args %q, want %q", tt.src, args, tt.args)
+		} else {
+			for i := range args {
+				if args[i] != tt.args[i] {
+					t.Errorf("Parse(%q): got args %q, want %q", tt.src, args, tt.args)
+					break
+				}
+			}
+		}
+		if len(call.Redirs) != tt.redirs {
+			t.Errorf("Parse(%q): got %d redirections, want %d", tt.src, len(call.Redirs), tt.redirs)
+		}
+	}
+}
+
+// parExpOps spells out the operators of ParExpOperator, in order.
+var parExpOps = []string{"-", ":-", "=", ":=", "?", ":?", "+", ":+", "#", "##", "%", "%%"}
+
+// render prints a syntax tree in a compact form that spells out its
+// structure: pipelines in brackets, && and || in parentheses and every
+// word part in its normalized notation.
+func render(node any) string {
+	var sb strings.Builder
+	var stmts func([]*Stmt)
+	var cmd func(Command)
+	var word func(*Word)
+	var part func(WordPart)
+	stmts = func(list []*Stmt) {
+		for i, s := range list {
+			if i > 0 {
+				sb.WriteString("; ")
+			}
+			cmd(s.Cmd)
+			if s.Background {
+				sb.WriteString(" &")
+			}
+		}
+	}
+	word = func(w *Word) {
+		if w != nil {
+			for _, p := range w.Parts {
+				part(p)
+			}
+		}
+	}
+	part = func(p WordPart) {
+		switch p := p.(type) {
+		case *Lit:
+			sb.WriteString(p.Value)
+		case *SglQuoted:
+			fmt.Fprintf(&sb, "'%s'", p.Value)
+		case *DblQuoted:
+			sb.WriteByte('"')
+			for _, inner := range p.Parts {
+				part(inner)
+			}
+			sb.WriteByte('"')
+		case *ParamExp:
+			sb.WriteString("${")
+			if p.Length {
+				sb.WriteByte('#')
+			}
+			sb.WriteString(p.Param)
+			if p.Exp != nil {
+				sb.WriteString(parExpOps[p.Exp.Op])
+				word(p.Exp.Word)
+			}
+			if p.Repl != nil {
+				sb.WriteByte('/')
+				if p.Repl.All {
+					sb.WriteByte('/')
+				}
+				if p.Repl.Anchor != 0 {
+					sb.WriteByte(p.Repl.Anchor)
+				}
+				word(p.Repl.Orig)
+				sb.WriteByte('/')
+				word(p.Repl.With)
+			}
+			sb.WriteByte('}')
+		case *CmdSubst:
+			sb.WriteString("$(")
+			stmts(p.Stmts)
+			sb.WriteByte(')')
+		case *ArithmExp:
+			sb.WriteString("$((")
+			word(p.X)
+			sb.WriteString("))")
+		}
+	}
+	redirs := func(list []*Redirect) {
+		for _, r := range list {
+			sb.WriteByte(' ')
+			if r.N >= 0 {
+				sb.WriteString(strconv.Itoa(r.N))
+			}
+			sb.WriteString(r.Op.String())
+			word(r.Word)
+			if r.Hdoc != nil {
+				sb.WriteByte('[')
+				word(r.Hdoc)
+				sb.WriteByte(']')
+			}
+		}
+	}
+	cmd = func(c Command) {
+		switch c := c.(type) {
+		case *CallExpr:
+			var fields []string
+			for _, as := range c.Assigns {
+				op := "="
+				if as.Append {
+					op = "+="
+				}
+				fields = append(fields, as.Name+op+render(as.Value))
+			}
+			for _, w := range c.Args {
+				fields = append(fields, render(w))
+			}
+			sb.WriteString(strings.Join(fields, " "))
+			redirs(c.Redirs)
+		case *Pipeline:
+			if c.Negated {
+				sb.WriteByte('!')
+			}
+			sb.WriteByte('[')
+			for i, sub := range c.Cmds {
+				if i > 0 {
+					sb.WriteString(" | ")
+				}
+				cmd(sub)
+			}
+			sb.WriteByte(']')
+		case *BinaryCmd:
+			sb.WriteByte('(')
+			cmd(c.X)
+			fmt.Fprintf(&sb, " %s ", c.Op)
+			cmd(c.Y)
+			sb.WriteByte(')')
+		case *ArithmCmd:
+			sb.WriteString("((")
+			word(c.X)
+			sb.WriteString("))")
+		case *Block:
+			sb.WriteString("{ ")
+			stmts(c.Stmts)
+			sb.WriteString("; }")
+			redirs(c.Redirs)
+		case *FuncDecl:
+			sb.WriteString(c.Name + "() ")
+			cmd(c.Body)
+		case *IfClause:
+			sb.WriteString("if ")
+			for ic := c; ic != nil; ic = ic.Else {
+				if len(ic.Cond) > 0 {
+					if ic != c {
+						sb.WriteString("; elif ")
+					}
+					stmts(ic.Cond)
+					sb.WriteString("; then ")
+				} else {
+					sb.WriteString("; else ")
+				}
+				stmts(ic.Then)
+			}
+			sb.WriteString("; fi")
+			redirs(c.Redirs)
+		case *WhileClause:
+			if c.Until {
+				sb.WriteString("until ")
+			} else {
+				sb.WriteString("while ")
+			}
+			stmts(c.Cond)
+			sb.WriteString("; do ")
+			stmts(c.Do)
+			sb.WriteString("; done")
+			redirs(c.Redirs)
+		case *ForClause:
+			sb.WriteString("for " + c.Name)
+			if c.HasIn {
+				sb.WriteString(" in")
+				for _, w := range c.Items {
+					sb.WriteByte(' ')
+					word(w)
+				}
+			}
+			sb.WriteString("; do ")
+			stmts(c.Do)
+			sb.WriteString("; done")
+			redirs(c.Redirs)
+		case *ArithmForClause:
+			sb.WriteString("for ((")
+			word(c.Init)
+			sb.WriteByte(';')
+			word(c.Cond)
+			sb.WriteByte(';')
+			word(c.Post)
+			sb.WriteString(")); do ")
+			stmts(c.Do)
+			sb.WriteString("; done")
+			redirs(c.Redirs)
+		case *CaseClause:
+			sb.WriteString("case ")
+			word(c.Word)
+			sb.WriteString(" in")
+			for _, item := range c.Items {
+				var patterns []string
+				for _, w := range item.Patterns {
+					patterns = append(patterns, render(w))
+				}
+				fmt.Fprintf(&sb, " %s) ", strings.Join(patterns, "|"))
+				stmts(item.Stmts)
+				sb.WriteString([]string{";;", ";&", ";;&"}[item.Op])
+			}
+			sb.WriteString(" esac")
+			redirs(c.Redirs)
+		}
+	}
+	switch n := node.(type) {
+	case *File:
+		stmts(n.Stmts)
+	case *Word:
+		word(n)
+	}
+	return sb.String()
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// Simple commands, words and quoting
+		{"echo  a\tb", "echo a b"},
+		{`echo a"b c"'d e'f`, `echo a"b c"'d e'f`},
+		{`echo a\ b \"c\"`, `echo a\ b \"c\"`},
+		{`echo "a\"b" 'c\d'`, `echo "a\"b" 'c\d'`},
+		{"echo a # comment", "echo a"},
+		{"echo a#b", "echo a#b"},
+		{"a=1 b+=2 c= cmd x=y", "a=1 b+=2 c= cmd x=y"},
+		{`a="x y"`, `a="x y"`},
+		{"echo a\\\nb", "echo a\\\nb"},
+
+		// Lists and operators
+		{"a; b & c", "a; b &; c"},
+		{"a | b | c", "[a | b | c]"},
+		{"! a | b", "![a | b]"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a | b && c | d", "([a | b] && [c | d])"},
+		{"a &&\nb", "(a && b)"},
+		{"a |\n\nb", "[a | b]"},
+
+		// Redirections
+		{"cmd >out 2>&1 <in", "cmd >out 2>&1 <in"},
+		{"cmd >>log 2>/dev/null &>all 3<>rw", "cmd >>log 2>/dev/null &>all 3<>rw"},
+		{"cmd <<<'text' >|f", "cmd <<<'text' >|f"},
+		{"cat <<EOF\nhi $x\nEOF", "cat <<EOF[hi ${x}\n]"},
+		{"cat <<'EOF'\nhi $x\nEOF", "cat <<'EOF'[hi $x\n]"},
+		{"cat <<-EOF\n\thi\n\tEOF", "cat <<-EOF[hi\n]"},
+
+		// Expansions
+		{"echo $a ${b} $1 $# $? $@", "echo ${a} ${b} ${1} ${#} ${?} ${@}"},
+		{"echo ${#a} ${a:-x y} ${a-} ${a:=$b} ${a?err} ${a:+alt}", "echo ${#a} ${a:-x y} ${a-} ${a:=${b}} ${a?err} ${a:+alt}"},
+		{"echo ${a#*/} ${a##*/} ${a%.*} ${a%%.*}", "echo ${a#*/} ${a##*/} ${a%.*} ${a%%.*}"},
+		{"echo ${a/x/y} ${a//x} ${a/#x/y} ${a/%x/y}", "echo ${a/x/y} ${a//x/} ${a/#x/y} ${a/%x/y}"},
+		{`echo "$a${b}" "$(c "d")"`, `echo "${a}${b}" "$(c "d")"`},
+		{"echo $(a | b; c) `d e`", "echo $([a | b]; c) $(d e)"},
+		{"echo $((1 + $x * 2))", "echo $((1 + ${x} * 2))"},
+		{"echo $ a$", "echo $ a$"},
+
+		// Compound commands
+		{"{ a; b; } >f", "{ a; b; } >f"},
+		{"f() { a; }", "f() { a; }"},
+		{"function f { a; }", "f() { a; }"},
+		{"if a; then b; elif c; then d; else e; fi", "if a; then b; elif c; then d; else e; fi"},
+		{"while a; do b; done", "while a; do b; done"},
+		{"until a\ndo\nb\ndone", "until a; do b; done"},
+		{"for i in 1 \"2 3\"; do echo $i; done", `for i in 1 "2 3"; do echo ${i}; done`},
+		{"for i; do a; done", "for i; do a; done"},
+		{"for ((i = 0; i < 3; i++)); do a; done", "for ((i = 0; i < 3; i++)); do a; done"},
+		{"case $x in a|b) c;; d) e;& *) f;;& esac", "case ${x} in a|b) c;; d) e;& *) f;;& esac"},
+		{"((n += 1))", "((n += 1))"},
+		{"if a; then b; fi | c", "[if a; then b; fi | c]"},
+		{"echo if then fi", "echo if then fi"},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := render(f); got != tt.want {
+			t.Errorf("Parse(%q):\ngot  %s\nwant %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src        string
+		incomplete bool
+		heredoc    bool
+	}{
+		{`echo "abc`, true, false},
+		{"echo 'abc", true, false},
+		{"echo $(ls", true, false},
+		{"echo ${a", true, false},
+		{"a |", true, false},
+		{"a &&", true, false},
+		{"echo a\\", true, false},
+		{"if a; then b", true, false},
+		{"for i in a; do", true, false},
+		{"case x in", true, false},
+		{"{ a;", true, false},
+		{"a >", true, false},
+		{"cat <<EOF\nbody", true, true},
+		{"a | | b", false, false},
+		{"; a", false, false},
+		{"fi", false, false},
+		{"a )", false, false},
+		{"if; then a; fi", false, false},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Parse(%q): got error %v, want a *ParseError", tt.src, err)
+			continue
+		}
+		if perr.Incomplete != tt.incomplete || perr.Heredoc != tt.heredoc {
+			t.Errorf("Parse(%q): got Incomplete %v, Heredoc %v, want %v, %v", tt.src, perr.Incomplete, perr.Heredoc, tt.incomplete, tt.heredoc)
+		}
+	}
+}
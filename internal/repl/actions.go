@@ -0,0 +1,360 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+
+	"dush/internal/app"
+)
+
+// errAccept is returned by accept-line to end the line.
+var errAccept = errors.New("accept line")
+
+// An editFunc is an editing function, run for the key k bound to it. It
+// ends the line by returning errAccept, errInterrupted or io.EOF.
+type editFunc func(le *lineEditor, k key) error
+
+// editFuncs are the editing functions by name. The Vi motions are in
+// viMotions.
+var editFuncs map[string]editFunc
+
+// killFuncs are the functions whose kills add to the last kill when they
+// follow one another.
+var killFuncs = map[string]bool{
+	"kill-line":          true,
+	"backward-kill-line": true,
+	"kill-word":          true,
+	"backward-kill-word": true,
+	"unix-word-rubout":   true,
+}
+
+// maxKills is the number of kills the kill ring holds.
+const maxKills = 16
+
+func init() {
+	// Set here since some functions dispatch keys through editFuncs
+	editFuncs = map[string]editFunc{
+		"self-insert": func(le *lineEditor, k key) error {
+			if k.r < keyUnknown && unicode.IsPrint(k.r) {
+				le.insert(k.r)
+			}
+			return nil
+		},
+		"accept-line": func(le *lineEditor, k key) error {
+			return errAccept
+		},
+		"interrupt": func(le *lineEditor, k key) error {
+			return errInterrupted
+		},
+		"delete-char-or-eof": func(le *lineEditor, k key) error {
+			if len(le.line) == 0 {
+				return io.EOF
+			}
+			le.delete(le.pos, le.pos+1)
+			return nil
+		},
+		"delete-char": func(le *lineEditor, k key) error {
+			le.delete(le.pos, le.pos+1)
+			return nil
+		},
+		"backward-delete-char": func(le *lineEditor, k key) error {
+			le.delete(le.pos-1, le.pos)
+			return nil
+		},
+		"backward-char": func(le *lineEditor, k key) error {
+			le.pos = max(le.pos-1, 0)
+			return nil
+		},
+		"forward-char": func(le *lineEditor, k key) error {
+			// At the end of the line it accepts the suggestion
+			if !le.acceptSuggestion(false) {
+				le.pos = min(le.pos+1, len(le.line))
+			}
+			return nil
+		},
+		"beginning-of-line": func(le *lineEditor, k key) error {
+			le.pos = 0
+			return nil
+		},
+		"end-of-line": func(le *lineEditor, k key) error {
+			if !le.acceptSuggestion(false) {
+				le.pos = len(le.line)
+			}
+			return nil
+		},
+		"backward-word": func(le *lineEditor, k key) error {
+			le.pos = le.backwardWord(le.pos)
+			return nil
+		},
+		"forward-word": func(le *lineEditor, k key) error {
+			// At the end of the line it accepts a word of the suggestion
+			if !le.acceptSuggestion(true) {
+				le.pos = le.forwardWord(le.pos)
+			}
+			return nil
+		},
+		"kill-line": func(le *lineEditor, k key) error {
+			le.kill(le.pos, len(le.line))
+			return nil
+		},
+		"backward-kill-line": func(le *lineEditor, k key) error {
+			le.kill(0, le.pos)
+			return nil
+		},
+		"kill-word": func(le *lineEditor, k key) error {
+			le.kill(le.pos, le.forwardWord(le.pos))
+			return nil
+		},
+		"backward-kill-word": func(le *lineEditor, k key) error {
+			le.kill(le.backwardWord(le.pos), le.pos)
+			return nil
+		},
+		"unix-word-rubout": func(le *lineEditor, k key) error {
+			le.kill(le.wordStart(), le.pos)
+			return nil
+		},
+		"yank": func(le *lineEditor, k key) error {
+			if len(le.killRing) > 0 {
+				le.yankIndex = len(le.killRing) - 1
+				le.yank(le.killRing[le.yankIndex])
+			}
+			return nil
+		},
+		"yank-pop": func(le *lineEditor, k key) error {
+			// Replaces the text just yanked with the kill before it
+			if le.prevFunc != "yank" && le.prevFunc != "yank-pop" || len(le.killRing) == 0 {
+				return nil
+			}
+			le.delete(le.yankStart, le.pos)
+			le.yankIndex = (le.yankIndex + len(le.killRing) - 1) % len(le.killRing)
+			le.yank(le.killRing[le.yankIndex])
+			return nil
+		},
+		"transpose-chars": func(le *lineEditor, k key) error {
+			// Swaps the characters around the cursor, or the last two at the end
+			i := min(le.pos, len(le.line)-1)
+			if i > 0 {
+				le.line[i-1], le.line[i] = le.line[i], le.line[i-1]
+				le.pos = i + 1
+			}
+			return nil
+		},
+		"transpose-words": func(le *lineEditor, k key) error {
+			le.transposeWords()
+			return nil
+		},
+		"upcase-word": func(le *lineEditor, k key) error {
+			le.mapWord(unicode.ToUpper, unicode.ToUpper)
+			return nil
+		},
+		"downcase-word": func(le *lineEditor, k key) error {
+			le.mapWord(unicode.ToLower, unicode.ToLower)
+			return nil
+		},
+		"capitalize-word": func(le *lineEditor, k key) error {
+			le.mapWord(unicode.ToUpper, unicode.ToLower)
+			return nil
+		},
+		"undo": func(le *lineEditor, k key) error {
+			if n := len(le.undo); n > 0 {
+				le.line, le.pos = le.undo[n-1].line, le.undo[n-1].pos
+				le.undo = le.undo[:n-1]
+			}
+			return nil
+		},
+		"clear-screen": func(le *lineEditor, k key) error {
+			fmt.Fprint(le.out, "\x1b[H\x1b[2J")
+			le.cursorRow = 0
+			return nil
+		},
+		"complete": func(le *lineEditor, k key) error {
+			le.complete()
+			return nil
+		},
+		"previous-history": func(le *lineEditor, k key) error {
+			le.historyPrev()
+			return nil
+		},
+		"next-history": func(le *lineEditor, k key) error {
+			le.historyNext()
+			return nil
+		},
+		"reverse-search-history": func(le *lineEditor, k key) error {
+			return le.searchHistory(false)
+		},
+		"forward-search-history": func(le *lineEditor, k key) error {
+			return le.searchHistory(true)
+		},
+		"emacs-editing-mode": func(le *lineEditor, k key) error {
+			app.GetApp().SetOption(app.OptEmacs, true)
+			le.viCommand = false
+			return nil
+		},
+		"vi-editing-mode": func(le *lineEditor, k key) error {
+			app.GetApp().SetOption(app.OptVi, true)
+			le.viCommand = false
+			return nil
+		},
+	}
+	for name, fn := range viFuncs {
+		editFuncs[name] = fn
+	}
+}
+
+// searchHistory runs a history search and then the key that ended it.
+func (le *lineEditor) searchHistory(forward bool) error {
+	k, err := le.search(forward)
+	if err != nil {
+		return err
+	}
+	le.histIndex = len(le.history)
+	if k == (key{}) {
+		return nil // Cancelled
+	}
+	return le.dispatch(k)
+}
+
+// delete removes the characters from start to end, clamped to the line,
+// and leaves the cursor at start.
+func (le *lineEditor) delete(start, end int) {
+	start, end = max(start, 0), min(end, len(le.line))
+	if start >= end {
+		return
+	}
+	le.line = append(le.line[:start], le.line[end:]...)
+	le.pos = start
+}
+
+// kill deletes the characters from start to end and saves them in the
+// kill ring. A kill right after another adds to the text it saved, so
+// that it is yanked back whole.
+func (le *lineEditor) kill(start, end int) {
+	start, end = max(start, 0), min(end, len(le.line))
+	if start >= end {
+		return
+	}
+	text := string(le.line[start:end])
+	switch n := len(le.killRing); {
+	case n > 0 && killFuncs[le.prevFunc] && end <= le.pos:
+		le.killRing[n-1] = text + le.killRing[n-1]
+	case n > 0 && killFuncs[le.prevFunc]:
+		le.killRing[n-1] += text
+	default:
+		le.saveKill(text)
+	}
+	le.delete(start, end)
+}
+
+// saveKill adds text to the kill ring, dropping the oldest kill when full.
+func (le *lineEditor) saveKill(text string) {
+	le.killRing = append(le.killRing, text)
+	if len(le.killRing) > maxKills {
+		le.killRing = le.killRing[1:]
+	}
+}
+
+// yank inserts text at the cursor, remembering where for yank-pop.
+func (le *lineEditor) yank(text string) {
+	le.yankStart = le.pos
+	le.insertText(text)
+}
+
+// insertText inserts s at the cursor.
+func (le *lineEditor) insertText(s string) {
+	for _, r := range s {
+		le.insert(r)
+	}
+}
+
+// isWordRune reports whether r is part of a word for the Emacs word
+// functions: letters, digits and underscores.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// forwardWord returns the end of the word at or after i.
+func (le *lineEditor) forwardWord(i int) int {
+	for i < len(le.line) && !isWordRune(le.line[i]) {
+		i++
+	}
+	for i < len(le.line) && isWordRune(le.line[i]) {
+		i++
+	}
+	return i
+}
+
+// backwardWord returns the start of the word before i.
+func (le *lineEditor) backwardWord(i int) int {
+	for i > 0 && !isWordRune(le.line[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(le.line[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordStart returns the start of the blank-separated word before the
+// cursor.
+func (le *lineEditor) wordStart() int {
+	i := le.pos
+	for i > 0 && unicode.IsSpace(le.line[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(le.line[i-1]) {
+		i--
+	}
+	return i
+}
+
+// transposeWords swaps the word before the cursor with the one after it,
+// or the last two words at the end of the line, and moves the cursor
+// past them.
+func (le *lineEditor) transposeWords() {
+	end2 := le.forwardWord(le.pos)
+	start2 := le.backwardWord(end2)
+	start1 := le.backwardWord(start2)
+	end1 := le.forwardWord(start1)
+	if start1 == start2 || end1 > start2 {
+		return
+	}
+	var line []rune
+	line = append(line, le.line[:start1]...)
+	line = append(line, le.line[start2:end2]...)
+	line = append(line, le.line[end1:start2]...)
+	line = append(line, le.line[start1:end1]...)
+	line = append(line, le.line[end2:]...)
+	le.line, le.pos = line, end2
+}
+
+// mapWord maps the first letter of the word at or after the cursor with
+// first and the rest with rest, and moves the cursor past it.
+func (le *lineEditor) mapWord(first, rest func(rune) rune) {
+	end := le.forwardWord(le.pos)
+	start := le.pos
+	for start < end && !isWordRune(le.line[start]) {
+		start++
+	}
+	for i := start; i < end; i++ {
+		if i == start {
+			le.line[i] = first(le.line[i])
+		} else {
+			le.line[i] = rest(le.line[i])
+		}
+	}
+	le.pos = end
+}
+
+// complete completes the word before the cursor.
+func (le *lineEditor) complete() {
+	line := string(le.line)
+	bytePos := len(string(le.line[:le.pos]))
+	newLine, newPos, ok := le.autoComplete(line, bytePos)
+	if !ok {
+		return
+	}
+	le.line = []rune(newLine)
+	le.pos = len([]rune(newLine[:newPos]))
+}
@@ -0,0 +1,324 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"dush/internal/app"
+	"dush/internal/utils"
+
+	"golang.org/x/term"
+)
+
+// errInterrupted is returned by readLine when Ctrl-C aborts the line.
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads command lines from a terminal in raw mode. It lives as
+// long as the REPL, so that the history it navigates is that of the whole
+// session, seeded from the history file.
+type lineEditor struct {
+	prompt string
+	line   []rune
+	pos    int
+
+	in  *bufio.Reader
+	out io.Writer
+	fd  int // Terminal file descriptor, for its width
+
+	cursorRow int // Row of the cursor below the first row of the prompt
+
+	history    []string // Entries, oldest first, while a line is read
+	histIndex  int      // Entry shown, or len(history) for the line being typed
+	histPrefix string   // Text the entries shown must start with
+	pending    string   // Line being typed, kept while history is shown
+	lastSearch string   // Query of the last history search
+	suggest    bool     // Whether a suggestion from the history is shown
+
+	prevFunc  string      // Editing function run for the previous key
+	undo      []editState // Lines before each change, for undo
+	killRing  []string    // Killed text, newest last
+	yankIndex int         // Kill last yanked
+	yankStart int         // Where it was yanked
+
+	commands map[string]string // Highlight classes of command names
+
+	viCommand bool // In the Vi command keymap rather than the insert one
+	count     int  // Count typed before a Vi command
+	lastFind  struct {
+		cmd, r rune // Last f, F, t or T and its character, for ; and ,
+	}
+}
+
+// editState is the line and cursor saved for undo.
+type editState struct {
+	line []rune
+	pos  int
+}
+
+// newLineEditor returns a line editor reading keys from in and drawing on
+// out, for the terminal fd.
+func newLineEditor(in io.Reader, out io.Writer, fd int) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out, fd: fd}
+}
+
+// readLine reads a line after showing prompt, running the editing
+// function bound to each key in the keymap of the editing mode. It
+// returns errInterrupted on Ctrl-C and io.EOF on Ctrl-D in an empty line.
+func (le *lineEditor) readLine(prompt string) (string, error) {
+	le.prompt = prompt
+	le.line, le.pos, le.cursorRow = nil, 0, 0
+	le.history = utils.GetHistory()
+	le.histIndex = len(le.history)
+	le.undo, le.prevFunc = nil, ""
+	le.viCommand, le.count = false, 0
+	le.commands = make(map[string]string)
+	le.suggest = true
+	le.refresh()
+
+	for {
+		k, err := readKey(le.in)
+		if err != nil {
+			return "", err
+		}
+		switch err := le.dispatch(k); err {
+		case nil:
+		case errAccept:
+			le.finish()
+			return string(le.line), nil
+		case errInterrupted:
+			le.finish()
+			return "", err
+		default:
+			return "", err
+		}
+		le.refresh()
+	}
+}
+
+// keymap returns the keymap keys are looked up in.
+func (le *lineEditor) keymap() keymap {
+	switch {
+	case !app.GetApp().Option(app.OptVi):
+		return keymaps[emacsKeymap]
+	case le.viCommand:
+		return keymaps[viCommandKeymap]
+	default:
+		return keymaps[viInsertKeymap]
+	}
+}
+
+// dispatch runs the editing function bound to k. It saves the line for
+// undo when the function changes it, and a change other than a history
+// function starts a new history search.
+func (le *lineEditor) dispatch(k key) error {
+	if le.viCommand && le.viCount(k) {
+		return nil
+	}
+	name, ok := le.keymap()[k]
+	if !ok {
+		switch {
+		case le.viCommand:
+			return nil
+		case k.alt && app.GetApp().Option(app.OptVi):
+			// Esc typed quickly before a key in insert mode
+			viFuncs["vi-movement-mode"](le, k)
+			return le.dispatch(key{r: k.r})
+		case k.alt || k.r >= keyUnknown || !unicode.IsPrint(k.r):
+			return nil
+		}
+		name = "self-insert"
+	}
+
+	before := editState{line: append([]rune(nil), le.line...), pos: le.pos}
+	histIndex := le.histIndex
+	var err error
+	if motion, ok := viMotions[name]; ok && le.viCommand {
+		le.viMove(motion, k)
+	} else if fn, ok := editFuncs[name]; ok {
+		err = fn(le, k)
+	}
+
+	if string(le.line) != string(before.line) {
+		if le.histIndex == histIndex {
+			le.histIndex = len(le.history)
+		}
+		// Typing is undone a run of characters at a time
+		if name != "undo" && (name != "self-insert" || le.prevFunc != "self-insert") {
+			le.undo = append(le.undo, before)
+		}
+	}
+	if le.viCommand {
+		le.count = 0
+		// The cursor stays on a character in the command keymap
+		le.pos = max(min(le.pos, len(le.line)-1), 0)
+	}
+	le.prevFunc = name
+	return err
+}
+
+// insert inserts r at the cursor.
+func (le *lineEditor) insert(r rune) {
+	le.line = append(le.line, 0)
+	copy(le.line[le.pos+1:], le.line[le.pos:])
+	le.line[le.pos] = r
+	le.pos++
+}
+
+// setLine replaces the line, with the cursor at its end.
+func (le *lineEditor) setLine(s string) {
+	le.line = []rune(s)
+	le.pos = len(le.line)
+}
+
+// historyPrev shows the previous history entry that starts with the text
+// typed before navigating the history, skipping repeats of the line shown.
+func (le *lineEditor) historyPrev() {
+	if le.histIndex == len(le.history) {
+		le.pending = string(le.line)
+		le.histPrefix = le.pending
+	}
+	current := string(le.line)
+	for i := le.histIndex - 1; i >= 0; i-- {
+		if entry := le.history[i]; strings.HasPrefix(entry, le.histPrefix) && entry != current {
+			le.histIndex = i
+			le.setLine(entry)
+			return
+		}
+	}
+}
+
+// historyNext shows the next matching history entry, or the line that was
+// being typed after the last one.
+func (le *lineEditor) historyNext() {
+	if le.histIndex == len(le.history) {
+		return
+	}
+	current := string(le.line)
+	for i := le.histIndex + 1; i < len(le.history); i++ {
+		if entry := le.history[i]; strings.HasPrefix(entry, le.histPrefix) && entry != current {
+			le.histIndex = i
+			le.setLine(entry)
+			return
+		}
+	}
+	le.histIndex = len(le.history)
+	le.setLine(le.pending)
+}
+
+// finish moves the cursor past the end of the line once it is read,
+// clearing the suggestion.
+func (le *lineEditor) finish() {
+	le.pos = len(le.line)
+	le.suggest = false
+	le.refresh()
+	fmt.Fprint(le.out, "\r\n")
+}
+
+// refresh redraws the prompt and the line, highlighted and followed by
+// the dimmed suggestion, and places the cursor. Long lines wrap at the
+// width of the terminal.
+func (le *lineEditor) refresh() {
+	cols := 80
+	if width, _, err := term.GetSize(le.fd); err == nil && width > 0 {
+		cols = width
+	}
+
+	var buf strings.Builder
+	if le.cursorRow > 0 {
+		fmt.Fprintf(&buf, "\x1b[%dA", le.cursorRow)
+	}
+	buf.WriteString("\r\x1b[J")
+	buf.WriteString(le.prompt)
+
+	// Rows and columns advance as the terminal does: a character written
+	// in the last column leaves the cursor there until the next one.
+	row, col := 0, visibleWidth(le.prompt)
+	for col > cols {
+		row, col = row+1, col-cols
+	}
+	curRow, curCol := 0, 0
+	put := func(r rune) {
+		switch {
+		case r == '\n':
+			buf.WriteString("\r\n")
+			row, col = row+1, 0
+		case col >= cols:
+			buf.WriteRune(r)
+			row, col = row+1, 1
+		default:
+			buf.WriteRune(r)
+			col++
+		}
+	}
+	colors := le.highlight()
+	color := ""
+	for i, r := range le.line {
+		if i == le.pos {
+			curRow, curCol = wrapped(row, col, cols)
+		}
+		if colors[i] != color {
+			if color != "" {
+				buf.WriteString(utils.ColorReset)
+			}
+			buf.WriteString(colors[i])
+			color = colors[i]
+		}
+		put(r)
+	}
+	if color != "" {
+		buf.WriteString(utils.ColorReset)
+	}
+	if le.pos == len(le.line) {
+		curRow, curCol = wrapped(row, col, cols)
+	}
+	if rest := le.suggestion(); rest != "" {
+		buf.WriteString(utils.StyleFaint)
+		for _, r := range rest {
+			put(r)
+		}
+		buf.WriteString(utils.ColorReset)
+	}
+	if col >= cols {
+		buf.WriteString("\r\n")
+		row, col = row+1, 0
+	}
+	if row > curRow {
+		fmt.Fprintf(&buf, "\x1b[%dA", row-curRow)
+	}
+	buf.WriteString("\r")
+	if curCol > 0 {
+		fmt.Fprintf(&buf, "\x1b[%dC", curCol)
+	}
+	le.cursorRow = curRow
+	io.WriteString(le.out, buf.String())
+}
+
+// wrapped returns where the next character goes from row and col.
+func wrapped(row, col, cols int) (int, int) {
+	if col >= cols {
+		return row + 1, 0
+	}
+	return row, col
+}
+
+// visibleWidth returns the number of columns s takes on the terminal,
+// leaving out the escape sequences that set colors.
+func visibleWidth(s string) int {
+	width := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case inEscape:
+			inEscape = !(r >= '@' && r <= '~' && r != '[')
+		case r == keyEscape:
+			inEscape = true
+		default:
+			width++
+		}
+	}
+	return width
+}
@@ -0,0 +1,160 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/config"
+	"dush/internal/parser"
+	"dush/internal/utils"
+)
+
+// Classes of syntax highlighting, as the theme of config.piml names them.
+const (
+	hlCommand  = "command"  // External command
+	hlBuiltin  = "builtin"  // Builtin command
+	hlAlias    = "alias"    // Alias
+	hlFunction = "function" // Shell function
+	hlUnknown  = "unknown"  // Command that does not exist
+	hlKeyword  = "keyword"
+	hlAssign   = "assignment"
+	hlString   = "string"
+	hlVariable = "variable"
+	hlSubst    = "substitution"
+	hlOperator = "operator"
+	hlRedirect = "redirection"
+	hlComment  = "comment"
+)
+
+// defaultTheme colors the classes the theme of config.piml leaves out.
+var defaultTheme = map[string]string{
+	hlCommand:  "green",
+	hlBuiltin:  "cyan",
+	hlAlias:    "bright-cyan",
+	hlFunction: "blue",
+	hlUnknown:  "red",
+	hlKeyword:  "magenta",
+	hlAssign:   "bright-yellow",
+	hlString:   "yellow",
+	hlVariable: "bright-magenta",
+	hlSubst:    "bright-magenta",
+	hlOperator: "bold",
+	hlRedirect: "bright-blue",
+	hlComment:  "bright-black",
+}
+
+// spanClasses are the classes of the spans the parser finds, but for
+// commands, whose class depends on what they name.
+var spanClasses = map[parser.SpanKind]string{
+	parser.SpanKeyword:  hlKeyword,
+	parser.SpanAssign:   hlAssign,
+	parser.SpanString:   hlString,
+	parser.SpanVariable: hlVariable,
+	parser.SpanSubst:    hlSubst,
+	parser.SpanOperator: hlOperator,
+	parser.SpanRedirect: hlRedirect,
+	parser.SpanComment:  hlComment,
+}
+
+// themeColor returns the escape codes of a class.
+func themeColor(class string) string {
+	spec, ok := config.GetConfig().Theme[class]
+	if !ok {
+		spec = defaultTheme[class]
+	}
+	code, err := utils.ParseColor(spec)
+	if err != nil {
+		code, _ = utils.ParseColor(defaultTheme[class])
+	}
+	return code
+}
+
+// checkTheme reports the classes and colors of the theme that are not
+// known.
+func checkTheme(theme map[string]string) []string {
+	var problems []string
+	for class, spec := range theme {
+		if _, ok := defaultTheme[class]; !ok {
+			problems = append(problems, "unknown class '"+class+"'")
+		} else if _, err := utils.ParseColor(spec); err != nil {
+			problems = append(problems, class+": "+err.Error())
+		}
+	}
+	return problems
+}
+
+// highlight returns the escape codes coloring each character of the
+// line, "" for none.
+func (le *lineEditor) highlight() []string {
+	colors := make([]string, len(le.line))
+	src := string(le.line)
+
+	// Index of the character holding each byte of src
+	runeAt := make([]int, len(src)+1)
+	r := -1
+	for b := 0; b < len(src); b++ {
+		if utf8.RuneStart(src[b]) {
+			r++
+		}
+		runeAt[b] = r
+	}
+	runeAt[len(src)] = len(le.line)
+
+	for _, span := range parser.Spans(src) {
+		class := spanClasses[span.Kind]
+		if span.Kind == parser.SpanCommand {
+			class = le.commandClass(src[span.Pos:span.End])
+		}
+		code := themeColor(class)
+		for i := runeAt[span.Pos]; i < runeAt[span.End]; i++ {
+			colors[i] = code
+		}
+	}
+	return colors
+}
+
+// commandClass returns the class of the command name: in the order they
+// are looked up when it runs, an alias, a function, a builtin, a file in
+// the current directory or PATH, or an unknown command. Classes are kept
+// while a line is read.
+func (le *lineEditor) commandClass(name string) string {
+	if class, ok := le.commands[name]; ok {
+		return class
+	}
+	appInstance := app.GetApp()
+	_, isAlias := config.GetConfig().Aliases[name]
+	_, isFunc := appInstance.GetFunc(name)
+	_, isBuiltin := builtins.Lookup(name)
+
+	class := hlUnknown
+	switch {
+	case isAlias:
+		class = hlAlias
+	case isFunc:
+		class = hlFunction
+	case isBuiltin:
+		class = hlBuiltin
+	case isExternal(name):
+		class = hlCommand
+	}
+	le.commands[name] = class
+	return class
+}
+
+// isExternal reports whether name runs an external command.
+func isExternal(name string) bool {
+	appInstance := app.GetApp()
+	dir := appInstance.GetCurrentDir()
+	if !strings.ContainsAny(name, `/\`) {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	pathList, _ := appInstance.GetVar("PATH")
+	_, err := utils.LookPath(name, pathList, dir)
+	return err == nil
+}
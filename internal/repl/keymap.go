@@ -0,0 +1,339 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/config"
+)
+
+// keymap maps keys to the names of the editing functions they run.
+// Printable characters that are not bound insert themselves, except in
+// the Vi command keymap.
+type keymap map[key]string
+
+// Names of the keymaps.
+const (
+	emacsKeymap     = "emacs"
+	viInsertKeymap  = "vi-insert"
+	viCommandKeymap = "vi-command"
+)
+
+// keymaps are the keymaps by name. 'bind' and the key_bindings of
+// config.piml change them.
+var keymaps = map[string]keymap{
+	emacsKeymap: {
+		{r: keyEnter}:                "accept-line",
+		{r: keyCtrlJ}:                "accept-line",
+		{r: keyCtrlC}:                "interrupt",
+		{r: keyCtrlD}:                "delete-char-or-eof",
+		{r: keyDelete}:               "delete-char",
+		{r: keyBackspace}:            "backward-delete-char",
+		{r: keyCtrlH}:                "backward-delete-char",
+		{r: keyCtrlB}:                "backward-char",
+		{r: keyLeft}:                 "backward-char",
+		{r: keyCtrlF}:                "forward-char",
+		{r: keyRight}:                "forward-char",
+		{r: keyCtrlA}:                "beginning-of-line",
+		{r: keyHome}:                 "beginning-of-line",
+		{r: keyCtrlE}:                "end-of-line",
+		{r: keyEnd}:                  "end-of-line",
+		{r: 'b', alt: true}:          "backward-word",
+		{r: keyWordLeft}:             "backward-word",
+		{r: 'f', alt: true}:          "forward-word",
+		{r: keyWordRight}:            "forward-word",
+		{r: keyCtrlK}:                "kill-line",
+		{r: keyCtrlU}:                "backward-kill-line",
+		{r: 'd', alt: true}:          "kill-word",
+		{r: keyBackspace, alt: true}: "backward-kill-word",
+		{r: keyCtrlW}:                "unix-word-rubout",
+		{r: keyCtrlY}:                "yank",
+		{r: 'y', alt: true}:          "yank-pop",
+		{r: keyCtrlT}:                "transpose-chars",
+		{r: 't', alt: true}:          "transpose-words",
+		{r: 'u', alt: true}:          "upcase-word",
+		{r: 'l', alt: true}:          "downcase-word",
+		{r: 'c', alt: true}:          "capitalize-word",
+		{r: keyCtrlUnder}:            "undo",
+		{r: keyCtrlL}:                "clear-screen",
+		{r: keyTab}:                  "complete",
+		{r: keyCtrlP}:                "previous-history",
+		{r: keyUp}:                   "previous-history",
+		{r: keyCtrlN}:                "next-history",
+		{r: keyDown}:                 "next-history",
+		{r: keyCtrlR}:                "reverse-search-history",
+		{r: keyCtrlS}:                "forward-search-history",
+		{r: keyCtrlJ, alt: true}:     "vi-editing-mode",
+	},
+	viInsertKeymap: {
+		{r: keyEnter}:     "accept-line",
+		{r: keyCtrlJ}:     "accept-line",
+		{r: keyCtrlC}:     "interrupt",
+		{r: keyCtrlD}:     "delete-char-or-eof",
+		{r: keyDelete}:    "delete-char",
+		{r: keyBackspace}: "backward-delete-char",
+		{r: keyCtrlH}:     "backward-delete-char",
+		{r: keyLeft}:      "backward-char",
+		{r: keyRight}:     "forward-char",
+		{r: keyHome}:      "beginning-of-line",
+		{r: keyEnd}:       "end-of-line",
+		{r: keyWordLeft}:  "backward-word",
+		{r: keyWordRight}: "forward-word",
+		{r: keyCtrlU}:     "backward-kill-line",
+		{r: keyCtrlW}:     "unix-word-rubout",
+		{r: keyCtrlY}:     "yank",
+		{r: keyCtrlL}:     "clear-screen",
+		{r: keyTab}:       "complete",
+		{r: keyUp}:        "previous-history",
+		{r: keyDown}:      "next-history",
+		{r: keyCtrlR}:     "reverse-search-history",
+		{r: keyCtrlS}:     "forward-search-history",
+		{r: keyEscape}:    "vi-movement-mode",
+	},
+	viCommandKeymap: {
+		{r: keyEnter}:     "accept-line",
+		{r: keyCtrlJ}:     "accept-line",
+		{r: keyCtrlC}:     "interrupt",
+		{r: keyCtrlD}:     "delete-char-or-eof",
+		{r: keyCtrlL}:     "clear-screen",
+		{r: keyCtrlR}:     "reverse-search-history",
+		{r: '/'}:          "reverse-search-history",
+		{r: '?'}:          "forward-search-history",
+		{r: 'h'}:          "backward-char",
+		{r: keyLeft}:      "backward-char",
+		{r: keyBackspace}: "backward-char",
+		{r: 'l'}:          "forward-char",
+		{r: ' '}:          "forward-char",
+		{r: keyRight}:     "forward-char",
+		{r: '0'}:          "beginning-of-line",
+		{r: keyHome}:      "beginning-of-line",
+		{r: '^'}:          "vi-first-print",
+		{r: '$'}:          "end-of-line",
+		{r: keyEnd}:       "end-of-line",
+		{r: 'w'}:          "vi-forward-word",
+		{r: 'W'}:          "vi-forward-bigword",
+		{r: 'b'}:          "vi-backward-word",
+		{r: 'B'}:          "vi-backward-bigword",
+		{r: 'e'}:          "vi-end-word",
+		{r: 'E'}:          "vi-end-bigword",
+		{r: 'f'}:          "vi-find-next-char",
+		{r: 'F'}:          "vi-find-prev-char",
+		{r: 't'}:          "vi-till-next-char",
+		{r: 'T'}:          "vi-till-prev-char",
+		{r: ';'}:          "vi-repeat-find",
+		{r: ','}:          "vi-reverse-find",
+		{r: '%'}:          "vi-match",
+		{r: 'i'}:          "vi-insertion-mode",
+		{r: 'a'}:          "vi-append-mode",
+		{r: 'I'}:          "vi-insert-beg",
+		{r: 'A'}:          "vi-append-eol",
+		{r: 'x'}:          "vi-delete",
+		{r: keyDelete}:    "vi-delete",
+		{r: 'X'}:          "vi-rubout",
+		{r: 'r'}:          "vi-change-char",
+		{r: 's'}:          "vi-subst",
+		{r: 'S'}:          "vi-change-line",
+		{r: 'C'}:          "vi-change-eol",
+		{r: 'D'}:          "kill-line",
+		{r: 'd'}:          "vi-delete-to",
+		{r: 'c'}:          "vi-change-to",
+		{r: 'y'}:          "vi-yank-to",
+		{r: 'p'}:          "vi-put",
+		{r: 'P'}:          "vi-put-before",
+		{r: '~'}:          "vi-change-case",
+		{r: 'u'}:          "undo",
+		{r: 'k'}:          "previous-history",
+		{r: '-'}:          "previous-history",
+		{r: keyUp}:        "previous-history",
+		{r: 'j'}:          "next-history",
+		{r: '+'}:          "next-history",
+		{r: keyDown}:      "next-history",
+		{r: keyTab}:       "complete",
+	},
+}
+
+// currentKeymap returns the name of the keymap of the editing mode. Vi
+// mode starts each line in its insert keymap.
+func currentKeymap() string {
+	if app.GetApp().Option(app.OptVi) {
+		return viInsertKeymap
+	}
+	return emacsKeymap
+}
+
+// namedKeys are the names of keys in key specifications.
+var namedKeys = map[string]rune{
+	"Up":        keyUp,
+	"Down":      keyDown,
+	"Left":      keyLeft,
+	"Right":     keyRight,
+	"Home":      keyHome,
+	"End":       keyEnd,
+	"Delete":    keyDelete,
+	"C-Left":    keyWordLeft,
+	"C-Right":   keyWordRight,
+	"Tab":       keyTab,
+	"Enter":     keyEnter,
+	"Esc":       keyEscape,
+	"Backspace": keyBackspace,
+	"Space":     ' ',
+}
+
+// parseKeySpec parses a key specification: a character, a key name such
+// as "Up" or "Tab", "C-x" for Ctrl-x, or any of these after "M-" for Alt.
+// The "\C-x" and "\M-x" forms of readline are also accepted.
+func parseKeySpec(spec string) (key, error) {
+	var k key
+	s := spec
+	if strings.HasPrefix(s, `\M-`) || strings.HasPrefix(s, `\C-`) {
+		s = s[1:]
+	}
+	if rest, ok := strings.CutPrefix(s, "M-"); ok && rest != "" {
+		k.alt, s = true, strings.TrimPrefix(rest, `\`)
+	}
+	for name, r := range namedKeys {
+		if strings.EqualFold(s, name) {
+			k.r = r
+			return k, nil
+		}
+	}
+	if rest, ok := strings.CutPrefix(s, "C-"); ok && utf8.RuneCountInString(rest) == 1 {
+		switch c, _ := utf8.DecodeRuneInString(rest); {
+		case c == '?':
+			k.r = keyBackspace
+		case c == '/':
+			k.r = keyCtrlUnder
+		case c >= '@' && c <= '~':
+			k.r = c & 0x1f
+		default:
+			return key{}, fmt.Errorf("%s: invalid key", spec)
+		}
+		return k, nil
+	}
+	if s == `\e` {
+		k.r = keyEscape
+		return k, nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return key{}, fmt.Errorf("%s: invalid key", spec)
+	}
+	k.r, _ = utf8.DecodeRuneInString(s)
+	return k, nil
+}
+
+// keySpec returns the key specification of k, as parseKeySpec reads it.
+func keySpec(k key) string {
+	prefix := ""
+	if k.alt {
+		prefix = "M-"
+	}
+	for name, r := range namedKeys {
+		if r == k.r {
+			return prefix + name
+		}
+	}
+	switch {
+	case k.r == keyCtrlUnder:
+		return prefix + "C-_"
+	case k.r < ' ':
+		return prefix + "C-" + string(unicode.ToLower(k.r|0x40))
+	}
+	return prefix + string(k.r)
+}
+
+// lookupKeymap returns the keymap called name, or that of the editing
+// mode when name is empty. "vi" and "vi-move" are other names of the Vi
+// command keymap.
+func lookupKeymap(name string) (keymap, error) {
+	switch name {
+	case "":
+		name = currentKeymap()
+	case "vi", "vi-move":
+		name = viCommandKeymap
+	}
+	km, ok := keymaps[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid keymap name", name)
+	}
+	return km, nil
+}
+
+// binder gives the 'bind' builtin access to the keymaps.
+type binder struct{}
+
+func (binder) Bind(name, seq, fn string) error {
+	km, err := lookupKeymap(name)
+	if err != nil {
+		return err
+	}
+	k, err := parseKeySpec(seq)
+	if err != nil {
+		return err
+	}
+	if fn == "" {
+		delete(km, k)
+		return nil
+	}
+	if _, ok := editFuncs[fn]; !ok {
+		if _, ok := viMotions[fn]; !ok {
+			return fmt.Errorf("%s: unknown function name", fn)
+		}
+	}
+	km[k] = fn
+	return nil
+}
+
+func (binder) Bindings(name string) (map[string]string, error) {
+	km, err := lookupKeymap(name)
+	if err != nil {
+		return nil, err
+	}
+	bindings := make(map[string]string, len(km))
+	for k, fn := range km {
+		bindings[keySpec(k)] = fn
+	}
+	return bindings, nil
+}
+
+func (binder) Functions() []string {
+	var names []string
+	for name := range editFuncs {
+		names = append(names, name)
+	}
+	for name := range viMotions {
+		if _, ok := editFuncs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configureEditor sets the editing mode and the key bindings given in
+// config.piml. Errors in the bindings and theme are reported but not
+// fatal.
+func configureEditor(cfg *config.Config, errOut io.Writer) {
+	if strings.EqualFold(cfg.EditMode, "vi") {
+		app.GetApp().SetOption(app.OptVi, true)
+	} else {
+		app.GetApp().SetOption(app.OptEmacs, true)
+	}
+	for _, problem := range checkTheme(cfg.Theme) {
+		fmt.Fprintf(errOut, "dush: theme: %s\n", problem)
+	}
+	for seq, fn := range cfg.KeyBindings {
+		if err := (binder{}).Bind("", seq, fn); err != nil {
+			fmt.Fprintf(errOut, "dush: key_bindings: %v\n", err)
+		}
+	}
+}
+
+func init() {
+	builtins.SetBinder(binder{})
+}
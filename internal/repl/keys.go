@@ -0,0 +1,133 @@
+package repl
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+// Keys that do not produce a character are given runes in the Unicode
+// private use area.
+const (
+	keyUnknown rune = 0xe000 + iota
+	keyUp
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft  // Ctrl-Left
+	keyWordRight // Ctrl-Right
+)
+
+// Control characters with a meaning in the line editor.
+const (
+	keyCtrlA     = 'a' & 0x1f
+	keyCtrlB     = 'b' & 0x1f
+	keyCtrlC     = 'c' & 0x1f
+	keyCtrlD     = 'd' & 0x1f
+	keyCtrlE     = 'e' & 0x1f
+	keyCtrlF     = 'f' & 0x1f
+	keyCtrlG     = 'g' & 0x1f
+	keyCtrlH     = 'h' & 0x1f
+	keyCtrlJ     = 'j' & 0x1f
+	keyTab       = '\t'
+	keyCtrlK     = 'k' & 0x1f
+	keyCtrlL     = 'l' & 0x1f
+	keyEnter     = '\r'
+	keyCtrlN     = 'n' & 0x1f
+	keyCtrlP     = 'p' & 0x1f
+	keyCtrlR     = 'r' & 0x1f
+	keyCtrlS     = 's' & 0x1f
+	keyCtrlT     = 't' & 0x1f
+	keyCtrlU     = 'u' & 0x1f
+	keyCtrlW     = 'w' & 0x1f
+	keyCtrlY     = 'y' & 0x1f
+	keyCtrlUnder = '_' & 0x1f // Also sent for Ctrl-/
+	keyEscape    = 0x1b
+	keyBackspace = 0x7f
+)
+
+// key is a key press: a character, a control character or one of the
+// named keys above. Alt is set when the key was pressed with Alt, which
+// terminals send as Esc followed by the key.
+type key struct {
+	r   rune
+	alt bool
+}
+
+// readKey reads the next key press from r, decoding the escape sequences
+// terminals send for arrows and other special keys. An Esc that is not
+// directly followed by more input is the Esc key itself.
+func readKey(r *bufio.Reader) (key, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	if c != keyEscape || r.Buffered() == 0 {
+		return key{r: c}, nil
+	}
+	c, _, err = r.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	if c != '[' && c != 'O' {
+		return key{r: c, alt: true}, nil
+	}
+
+	// A control sequence: parameters, then a final byte from '@' to '~'
+	var params strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return key{}, err
+		}
+		if b >= '@' && b <= '~' {
+			return csiKey(params.String(), b), nil
+		}
+		params.WriteByte(b)
+	}
+}
+
+// csiKey returns the key for a control sequence with the given parameters
+// and final byte.
+func csiKey(params string, final byte) key {
+	fields := strings.Split(params, ";")
+	modifier := 1
+	if len(fields) > 1 {
+		modifier, _ = strconv.Atoi(fields[1])
+	}
+	// Modifiers 3 and 5 are Alt and Ctrl, which move by words
+	word := modifier == 3 || modifier == 5
+	switch final {
+	case 'A':
+		return key{r: keyUp}
+	case 'B':
+		return key{r: keyDown}
+	case 'C':
+		if word {
+			return key{r: keyWordRight}
+		}
+		return key{r: keyRight}
+	case 'D':
+		if word {
+			return key{r: keyWordLeft}
+		}
+		return key{r: keyLeft}
+	case 'H':
+		return key{r: keyHome}
+	case 'F':
+		return key{r: keyEnd}
+	case '~':
+		switch fields[0] {
+		case "1", "7":
+			return key{r: keyHome}
+		case "4", "8":
+			return key{r: keyEnd}
+		case "3":
+			return key{r: keyDelete}
+		}
+	}
+	return key{r: keyUnknown}
+}
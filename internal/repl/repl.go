@@ -3,50 +3,26 @@ package repl
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
-	"syscall"
 
 	"dush/internal/app"
 	"dush/internal/builtins"
 	"dush/internal/config"
 	"dush/internal/evaluator"
+	"dush/internal/jobs"
+	"dush/internal/parser"
+	"dush/internal/signals"
 	"dush/internal/utils"
 
 	"golang.org/x/term"
 )
 
-type lineEditor struct {
-	prompt string
-	line   []rune
-	pos    int
-}
-
-type terminalIO struct {
-	io.Reader
-	io.Writer
-}
-
-func (le *lineEditor) readLine(stdin io.Reader, stdout io.Writer) (string, error) {
-	t := term.NewTerminal(terminalIO{stdin, stdout}, le.prompt)
-
-	// Set autocomplete callback
-	t.AutoCompleteCallback = func(line string, pos int, key rune) (newLine string, newPos int, ok bool) {
-		if key == '\t' {
-			return le.autoComplete(line, pos)
-		}
-		return "", 0, false
-	}
-
-	return t.ReadLine()
-}
-
 func (le *lineEditor) autoComplete(line string, pos int) (string, int, bool) {
 	before := line[:pos]
 	after := line[pos:]
@@ -173,13 +149,53 @@ func (le *lineEditor) autoComplete(line string, pos int) (string, int, bool) {
 	return prev + completed + after, len(prev + completed), true
 }
 
+// continuationPrompt is the prompt shown while a command is incomplete,
+// unless PS2 is set.
+const continuationPrompt = "> "
+
+// readContinuation reads lines with readInput and appends them to src
+// while src is an incomplete command, such as an unterminated quote, a
+// trailing '|' or '&&', or an 'if' without its 'fi'. The lines are
+// prompted for with PS2.
+func readContinuation(src string, readInput func(prompt string) (string, error)) (string, error) {
+	for {
+		_, err := parser.Parse(src)
+		var perr *parser.ParseError
+		if !errors.As(err, &perr) || !perr.Incomplete {
+			return src, nil
+		}
+		prompt := continuationPrompt
+		if ps2, ok := app.GetApp().GetVar("PS2"); ok {
+			prompt = ps2
+		}
+		line, err := readInput(prompt)
+		if err != nil {
+			return "", err
+		}
+		src += "\n" + line
+	}
+}
+
 // Start starts the Read-Eval-Print Loop.
 // It takes an io.Reader for input, an io.Writer for output, and an io.Writer for error output.
-func Start(in io.Reader, out io.Writer, errOut io.Writer) {
+// The startup files are sourced first, see StartupFiles.
+// It returns the exit status of the shell: the status given to 'exit', or
+// that of the last command when input ends. The EXIT trap runs last.
+func Start(in io.Reader, out io.Writer, errOut io.Writer, startup []string) (status int) {
 	// Create a context for the entire REPL lifecycle, cancelled on SIGTERM/SIGHUP
-	replCtx, replCancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGHUP)
+	// unless they are trapped. Ctrl-C interrupts the running command instead.
+	replCtx, replCancel := signals.ShellContext(context.Background())
 	defer replCancel() // Ensure this context is cancelled when Start returns
 
+	// 'exit' records its status here and stops the running command
+	exit := &builtins.ExitState{}
+	replCtx = builtins.WithExit(replCtx, exit)
+
+	// Runs after the terminal is restored, whatever way the shell exits
+	defer func() {
+		status = evaluator.RunExitTrap(replCtx, status, out, errOut)
+	}()
+
 	// Load history at the start of the REPL
 	utils.LoadHistory()
 	// Ensure history is saved when the REPL exits
@@ -187,6 +203,7 @@ func Start(in io.Reader, out io.Writer, errOut io.Writer) {
 
 	// Get the singleton App instance
 	appInstance := app.GetApp()
+	appInstance.SetInteractive(true)
 
 	// Initialize currentCWD with the actual OS CWD at startup
 	initialCWD, err := os.Getwd()
@@ -202,6 +219,23 @@ func Start(in io.Reader, out io.Writer, errOut io.Writer) {
 	// Check if stdin is a terminal
 	isTerminal := term.IsTerminal(int(os.Stdin.Fd()))
 
+	// Job control needs the terminal; without it '&' still runs jobs
+	if isTerminal {
+		if err := jobs.Enable(int(os.Stdin.Fd())); err != nil {
+			fmt.Fprintf(errOut, "dush: %v\n", err)
+		} else {
+			appInstance.SetOption(app.OptMonitor, true)
+		}
+	}
+
+	// The editing mode and keys of config.piml, which the startup files
+	// may change with 'set -o vi' or 'bind'
+	configureEditor(cfg, errOut)
+
+	if !sourceStartupFiles(replCtx, startup, out, errOut) {
+		return exit.Status
+	}
+
 	var oldState *term.State
 	if isTerminal {
 		oldState, err = term.MakeRaw(int(os.Stdin.Fd()))
@@ -212,108 +246,115 @@ func Start(in io.Reader, out io.Writer, errOut io.Writer) {
 		}
 	}
 
+	// A single scanner is shared across iterations so buffered input is not lost
+	scanner := bufio.NewScanner(in)
+
+	// readInput reads a line of input after showing prompt
+	le := newLineEditor(in, out, int(os.Stdin.Fd()))
+	readInput := func(prompt string) (string, error) {
+		if isTerminal {
+			return le.readLine(prompt)
+		}
+		fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	}
+
 	for {
 		// Check if the main REPL context has been cancelled
 		select {
 		case <-replCtx.Done():
+			jobs.Hangup()
 			if isTerminal {
 				fmt.Fprintf(out, "\r\nExiting dush REPL gracefully...\n")
 			} else {
 				fmt.Fprintf(out, "\nExiting dush REPL gracefully...\n")
 			}
-			return
+			return appInstance.LastStatus()
 		default:
 			// Continue
 		}
 
+		// Report background jobs that finished or stopped since the last prompt
+		var notices strings.Builder
+		jobs.Report(&notices)
+		if isTerminal {
+			fmt.Fprint(errOut, strings.ReplaceAll(notices.String(), "\n", "\r\n"))
+		} else {
+			fmt.Fprint(errOut, notices.String())
+		}
+
 		currentCWD := appInstance.GetCurrentDir()
 		displayDirName := utils.GetDisplayDirName(currentCWD)
 
 		// Construct the dynamic prompt using App's currentCWD
 		promptLine := fmt.Sprintf("%s %s@%s%s ", cfg.PromptPrefix, cfg.UserName, displayDirName, cfg.PromptSuffix)
+		// Show the exit status of the previous command when it failed
+		if status := appInstance.LastStatus(); status != 0 {
+			promptLine = utils.Colorize(fmt.Sprintf("[%d]", status), utils.ColorRed) + promptLine
+		}
 
-		var line string
-		if isTerminal {
-			le := &lineEditor{prompt: promptLine}
-			line, err = le.readLine(in, out)
-			if err != nil {
-				if err == io.EOF {
-					term.Restore(int(os.Stdin.Fd()), oldState)
-					fmt.Fprintf(out, "\r\nExiting dush REPL.\n")
-					return
-				}
-				// Other errors...
+		line, err := readInput(promptLine)
+		if err == nil {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue // Skip empty lines
+			}
+			// An incomplete command goes on with the lines that follow
+			line, err = readContinuation(line, readInput)
+		}
+		if err != nil {
+			if err == errInterrupted {
+				// Ctrl-C discards the line being typed
+				fmt.Fprint(out, "^C\r\n")
+				appInstance.SetLastStatus(signals.ExitStatus(context.Background()))
 				continue
 			}
-		} else {
-			fmt.Fprintf(out, promptLine)
-			scanner := bufio.NewScanner(in)
-			if !scanner.Scan() {
+			if err == io.EOF {
+				if isTerminal {
+					term.Restore(int(os.Stdin.Fd()), oldState)
+					fmt.Fprintf(out, "\r\n")
+				}
 				fmt.Fprintf(out, "Exiting dush REPL.\n")
-				return
+				return appInstance.LastStatus()
 			}
-			line = scanner.Text()
+			// Other errors...
+			continue
 		}
 
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine == "" {
-			continue // Skip empty lines
-		}
+		// Add command to history before processing it, as a single entry
+		// even if it spans several lines
+		utils.AddCommandIn(line, appInstance.GetCurrentDir())
 
-		// Add command to history before processing it
-		utils.AddCommand(trimmedLine)
-
-		parts := strings.Fields(trimmedLine)
-		cmdName := parts[0]
-		args := parts[1:]
-
-		// --- Start Alias Expansion ---
-		if expandedValue, ok := cfg.Aliases[cmdName]; ok {
-			// If the command name is an alias, expand it
-			expandedParts := strings.Fields(expandedValue)
-			if len(expandedParts) > 0 {
-				cmdName = expandedParts[0]
-				// Append original args to expanded alias args
-				args = append(expandedParts[1:], args...)
-			}
+		file, err := parser.Parse(line)
+		if err != nil {
+			fmt.Fprintf(errOut, "dush: %v\n", err)
+			appInstance.SetLastStatus(2) // Syntax errors report status 2, as in other shells
+			continue
 		}
-		// --- End Alias Expansion ---
 
-		if cmdName == "exit" || cmdName == "quit" {
-			if isTerminal {
-				fmt.Fprintf(out, "\r\nExiting dush REPL.\n")
-			} else {
-				fmt.Fprintf(out, "Exiting dush REPL.\n")
-			}
-			return
+		// Commands run with the terminal in its normal mode, both so that
+		// externals behave and so that builtin output is not mangled.
+		if isTerminal {
+			term.Restore(int(os.Stdin.Fd()), oldState)
 		}
 
-		// Create a cancellable context for the current command
-		cmdCtx, cmdCancel := context.WithCancel(replCtx)
+		// The current command is cancelled by Ctrl-C and when it is done
+		cmdCtx, done := signals.Foreground(replCtx)
+		evaluator.Run(cmdCtx, file, out, errOut)
+		done()
+		// Traps of signals received while the prompt was shown
+		evaluator.RunPendingTraps(replCtx, out, errOut)
 
-		// Check and execute built-in commands
-		if builtins.RunBuiltin(cmdCtx, cmdName, args, out, errOut) {
-			// Builtin handled
-		} else {
-			// If not a built-in command, attempt to run as an external command
-			if isTerminal {
-				term.Restore(int(os.Stdin.Fd()), oldState)
-			}
-
-			err := evaluator.ExecuteExternal(cmdCtx, cmdName, args, out, errOut)
-			if err != nil {
-				if _, ok := err.(*exec.Error); ok {
-					fmt.Fprintf(out, "Command not found: %s\n", cmdName)
-				} else {
-					// Other errors (like execution failure)
-					fmt.Fprintf(out, "Error executing %s: %v\n", cmdName, err)
-				}
-			}
+		if exit.Exiting {
+			fmt.Fprintf(out, "Exiting dush REPL.\n")
+			return exit.Status
+		}
 
-			if isTerminal {
-				oldState, _ = term.MakeRaw(int(os.Stdin.Fd()))
-			}
+		if isTerminal {
+			oldState, _ = term.MakeRaw(int(os.Stdin.Fd()))
 		}
-		cmdCancel()
 	}
 }
@@ -0,0 +1,77 @@
+package repl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/evaluator"
+	"dush/internal/parser"
+	"dush/internal/signals"
+)
+
+// RunScript runs a script file non-interactively with args as the
+// positional parameters and returns the exit status of the shell: the
+// status given to 'exit', or that of the last command. A '#!' line at the
+// top of the file is a comment to the parser. The startup files are
+// sourced before the script is parsed.
+func RunScript(path string, args []string, startup []string, out io.Writer, errOut io.Writer) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(errOut, "dush: %s: No such file or directory\n", path)
+			return 127
+		}
+		fmt.Fprintf(errOut, "dush: %s: %v\n", path, err)
+		return 126
+	}
+	return runSource(string(src), path, path, args, startup, out, errOut)
+}
+
+// RunString runs the commands in src, as given to 'dush -c', with name as
+// $0 and args as the positional parameters. It returns the exit status of
+// the shell.
+func RunString(src string, name string, args []string, startup []string, out io.Writer, errOut io.Writer) int {
+	return runSource(src, "-c", name, args, startup, out, errOut)
+}
+
+// runSource parses and runs src, naming it origin in syntax errors. With
+// the noexec option the commands are only parsed. The EXIT trap runs last.
+func runSource(src string, origin string, name string, args []string, startup []string, out io.Writer, errOut io.Writer) (status int) {
+	appInstance := app.GetApp()
+	appInstance.SetScriptName(name)
+
+	ctx, cancel := signals.ShellContext(context.Background())
+	defer cancel()
+	ctx, done := signals.Foreground(ctx)
+	defer done()
+	exit := &builtins.ExitState{}
+	ctx = builtins.WithExit(ctx, exit)
+	defer func() {
+		status = evaluator.RunExitTrap(ctx, status, out, errOut)
+	}()
+	if !sourceStartupFiles(ctx, startup, out, errOut) {
+		return exit.Status
+	}
+	app.CurrentFrame(ctx).SetParams(args)
+
+	file, err := parser.Parse(src)
+	if err != nil {
+		fmt.Fprintf(errOut, "dush: %s: %v\n", origin, err)
+		return 2
+	}
+	if appInstance.Option(app.OptNoexec) {
+		return 0
+	}
+
+	status = evaluator.Run(ctx, file, out, errOut)
+	if exit.Exiting {
+		return exit.Status
+	}
+	return status
+}
@@ -0,0 +1,224 @@
+package repl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"dush/internal/config"
+	"dush/internal/utils"
+)
+
+// historySearch is the state of an incremental history search.
+type historySearch struct {
+	query    []rune
+	fuzzy    bool
+	forward  bool         // Ctrl-S was pressed last
+	matches  []matchEntry // Best match first
+	index    int          // Match shown
+	original string       // Line before the search, restored on Ctrl-G
+}
+
+// matchEntry is a history entry matching the query, with the position of
+// the cursor on it.
+type matchEntry struct {
+	entry string
+	pos   int
+}
+
+// search runs an incremental history search, started by Ctrl-R or, with
+// forward set, Ctrl-S. Typed characters extend the query, Ctrl-R and
+// Ctrl-S move to the next and previous match, and Ctrl-G or Esc restore
+// the line as it was. Any other key leaves the search with the match on
+// the line and is returned so that the editor handles it: Enter runs the
+// match, and keys such as the arrows start editing it. The zero key is
+// returned when the search was cancelled.
+func (le *lineEditor) search(forward bool) (key, error) {
+	s := &historySearch{
+		fuzzy:    strings.EqualFold(config.GetConfig().HistorySearch, "fuzzy"),
+		forward:  forward,
+		original: string(le.line),
+	}
+	prompt := le.prompt
+	le.suggest = false
+	defer func() { le.prompt, le.suggest = prompt, true }()
+
+	for {
+		le.showSearch(s)
+		k, err := readKey(le.in)
+		if err != nil {
+			return key{}, err
+		}
+		switch {
+		case k.alt:
+			return k, nil
+		case k.r == keyCtrlR || k.r == keyCtrlS:
+			s.forward = k.r == keyCtrlS
+			if len(s.query) == 0 && len(s.matches) == 0 {
+				// As in other shells, searching again with no query
+				// reuses the last one
+				s.query = []rune(le.lastSearch)
+				le.findMatches(s)
+			} else if s.forward {
+				s.index = max(s.index-1, 0)
+			} else {
+				s.index = min(s.index+1, max(len(s.matches)-1, 0))
+			}
+		case k.r == keyBackspace || k.r == keyCtrlH:
+			if len(s.query) > 0 {
+				s.query = s.query[:len(s.query)-1]
+				le.findMatches(s)
+			}
+		case k.r == keyCtrlG || k.r == keyEscape:
+			le.prompt = prompt
+			le.setLine(s.original)
+			return key{}, nil
+		case k.r < keyUnknown && unicode.IsPrint(k.r):
+			s.query = append(s.query, k.r)
+			le.findMatches(s)
+		default:
+			le.prompt = prompt
+			if len(s.matches) > 0 {
+				m := s.matches[s.index]
+				le.setLine(m.entry)
+				le.pos = m.pos
+			} else {
+				le.setLine(s.original)
+			}
+			if len(s.query) > 0 {
+				le.lastSearch = string(s.query)
+			}
+			return k, nil
+		}
+	}
+}
+
+// showSearch draws the search prompt with the query and the match.
+func (le *lineEditor) showSearch(s *historySearch) {
+	label := "reverse-i-search"
+	switch {
+	case s.fuzzy:
+		label = "fuzzy-search"
+	case s.forward:
+		label = "i-search"
+	}
+	if len(s.query) > 0 && len(s.matches) == 0 {
+		label = "failed " + label
+	}
+	le.prompt = fmt.Sprintf("(%s)`%s': ", label, string(s.query))
+	if len(s.matches) == 0 {
+		le.setLine(s.original)
+	} else {
+		m := s.matches[s.index]
+		le.setLine(m.entry)
+		le.pos = m.pos
+	}
+	le.refresh()
+}
+
+// findMatches lists the history entries matching the query. Exact matches
+// are listed newest first; fuzzy ones by score. The search starts at the
+// best match, or at the oldest one when searching forward.
+func (le *lineEditor) findMatches(s *historySearch) {
+	s.matches, s.index = nil, 0
+	if len(s.query) == 0 {
+		return
+	}
+	if s.fuzzy {
+		s.matches = fuzzyMatches(le.history, string(s.query))
+	} else {
+		seen := make(map[string]bool)
+		for i := len(le.history) - 1; i >= 0; i-- {
+			entry := le.history[i]
+			at := strings.Index(entry, string(s.query))
+			if at < 0 || seen[entry] {
+				continue
+			}
+			seen[entry] = true
+			s.matches = append(s.matches, matchEntry{entry, len([]rune(entry[:at]))})
+		}
+	}
+	if s.forward && len(s.matches) > 0 {
+		s.index = len(s.matches) - 1
+	}
+}
+
+// fuzzyMatches returns the history entries holding the characters of query
+// in order, best first. The score of an entry adds how well it matches
+// to how recently and how often it was run.
+func fuzzyMatches(history []string, query string) []matchEntry {
+	type candidate struct {
+		match matchEntry
+		score float64
+	}
+	last := make(map[string]int)
+	count := make(map[string]int)
+	for i, entry := range history {
+		last[entry] = i
+		count[entry]++
+	}
+
+	var candidates []candidate
+	for entry, i := range last {
+		score, pos, ok := fuzzyScore(entry, query)
+		if !ok {
+			continue
+		}
+		recency := float64(i+1) / float64(len(history))
+		// Runs of past sessions are counted by the history, since the
+		// history file keeps each command once
+		runs := max(count[entry], utils.CommandCount(entry))
+		frequency := math.Log2(float64(runs) + 1)
+		candidates = append(candidates, candidate{
+			match: matchEntry{entry, pos},
+			score: float64(score) + 4*recency + 2*frequency,
+		})
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		if candidates[a].score != candidates[b].score {
+			return candidates[a].score > candidates[b].score
+		}
+		return last[candidates[a].match.entry] > last[candidates[b].match.entry]
+	})
+
+	matches := make([]matchEntry, len(candidates))
+	for i, c := range candidates {
+		matches[i] = c.match
+	}
+	return matches
+}
+
+// fuzzyScore matches the characters of query in order against entry,
+// ignoring case. Characters that follow each other or start a word score
+// more, and gaps between them score less. It returns the score, the
+// position of the first character matched and whether all of them were.
+func fuzzyScore(entry, query string) (score, pos int, ok bool) {
+	text := []rune(strings.ToLower(entry))
+	want := []rune(strings.ToLower(query))
+	pos = -1
+	prev := -1
+	j := 0
+	for i := 0; i < len(text) && j < len(want); i++ {
+		if text[i] != want[j] {
+			continue
+		}
+		score++
+		switch {
+		case prev >= 0 && i == prev+1:
+			score += 3
+		case prev >= 0:
+			score -= min(i-prev-1, 3)
+		}
+		if i == 0 || strings.ContainsRune(" /-_.|;&", text[i-1]) {
+			score += 2
+		}
+		if pos < 0 {
+			pos = i
+		}
+		prev = i
+		j++
+	}
+	return score, pos, j == len(want)
+}
@@ -0,0 +1,100 @@
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/builtins"
+	"dush/internal/evaluator"
+)
+
+// Startup files, sourced in this order by the shells they apply to. Paths
+// relative to the user's home directory start with "~/".
+const (
+	systemProfile = "/etc/dush/profile.dush" // Login shells
+	userProfile   = "~/.dush/profile.dush"   // Login shells
+	systemRC      = "/etc/dush/rc.dush"      // Interactive shells
+	userRC        = "~/.dush/rc.dush"        // Interactive shells
+	dirRC         = ".dushrc"                // Interactive shells, if its directory is trusted
+	trustedDirs   = "~/.dush/trusted"        // Directories whose .dushrc may run, one per line
+)
+
+// StartupFiles returns the startup scripts a shell sources before reading
+// commands. Login shells read the profiles; interactive shells then read
+// the rc files, ending with the .dushrc of the current directory if that
+// directory is listed in ~/.dush/trusted. Files that do not exist are
+// skipped when sourcing.
+func StartupFiles(login bool, interactive bool) []string {
+	var files []string
+	if login {
+		files = append(files, systemProfile, expandHome(userProfile))
+	}
+	if interactive {
+		files = append(files, systemRC, expandHome(userRC))
+		dir := app.GetApp().GetCurrentDir()
+		if isTrustedDir(dir) {
+			files = append(files, filepath.Join(dir, dirRC))
+		}
+	}
+	return files
+}
+
+// expandHome replaces a leading "~/" in path with the user's home
+// directory, $HOME if set, as '~' and the history file use.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// isTrustedDir reports whether dir is listed in the trusted directories
+// file. Blank lines and lines starting with '#' are ignored.
+func isTrustedDir(dir string) bool {
+	f, err := os.Open(expandHome(trustedDirs))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if filepath.Clean(expandHome(line)) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceStartupFiles sources each existing startup file in the current
+// shell and reports whether the shell should keep going, which it does not
+// once a file runs 'exit'.
+func sourceStartupFiles(ctx context.Context, files []string, out io.Writer, errOut io.Writer) bool {
+	for _, path := range files {
+		_, err := evaluator.SourceFile(ctx, path, nil, out, errOut)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(errOut, "dush: %s: %v\n", path, err)
+		}
+		if exit := builtins.Exit(ctx); exit != nil && exit.Exiting {
+			return false
+		}
+	}
+	return true
+}
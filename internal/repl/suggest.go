@@ -0,0 +1,56 @@
+package repl
+
+import (
+	"strings"
+
+	"dush/internal/app"
+	"dush/internal/utils"
+)
+
+// suggestion returns the text suggested after the line: the rest of the
+// most recent history entry that starts with it, preferring entries run in
+// the current directory. There is none unless the cursor is at the end of
+// a line being typed, nor for entries spanning several lines.
+func (le *lineEditor) suggestion() string {
+	if !le.suggest || len(le.line) == 0 || le.pos != len(le.line) || le.viCommand {
+		return ""
+	}
+	line := string(le.line)
+	dir := app.GetApp().GetCurrentDir()
+	found := ""
+	for i := len(le.history) - 1; i >= 0; i-- {
+		entry := le.history[i]
+		rest, ok := strings.CutPrefix(entry, line)
+		if !ok || rest == "" || strings.Contains(rest, "\n") {
+			continue
+		}
+		if utils.CommandDir(entry) == dir {
+			return rest
+		}
+		if found == "" {
+			found = rest
+		}
+	}
+	return found
+}
+
+// acceptSuggestion adds the suggestion to the line, or with word set only
+// up to the end of its first word, and reports whether there was one.
+func (le *lineEditor) acceptSuggestion(word bool) bool {
+	rest := []rune(le.suggestion())
+	if len(rest) == 0 {
+		return false
+	}
+	if word {
+		i := 0
+		for i < len(rest) && !isWordRune(rest[i]) {
+			i++
+		}
+		for i < len(rest) && isWordRune(rest[i]) {
+			i++
+		}
+		rest = rest[:i]
+	}
+	le.insertText(string(rest))
+	return true
+}
@@ -0,0 +1,570 @@
+package repl
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A viMotion returns where a Vi motion repeated count times moves the
+// cursor, whether an operator such as 'd' includes the character there,
+// and false if the motion is not possible. k is the key of the motion.
+type viMotion func(le *lineEditor, k key, count int) (pos int, inclusive bool, ok bool)
+
+// viMotions are the Vi motions by name. In the Vi command keymap they
+// move the cursor and follow the operators.
+var viMotions = map[string]viMotion{
+	"backward-char": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return max(le.pos-count, 0), false, le.pos > 0
+	},
+	"forward-char": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return min(le.pos+count, len(le.line)), false, le.pos < len(le.line)
+	},
+	"beginning-of-line": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return 0, false, true
+	},
+	"vi-first-print": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.firstPrint(), false, true
+	},
+	"end-of-line": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return len(le.line), false, true
+	},
+	"vi-forward-word": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.repeatMotion(count, le.viWordForward, false), false, true
+	},
+	"vi-forward-bigword": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.repeatMotion(count, le.viWordForward, true), false, true
+	},
+	"vi-backward-word": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.repeatMotion(count, le.viWordBackward, false), false, true
+	},
+	"vi-backward-bigword": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.repeatMotion(count, le.viWordBackward, true), false, true
+	},
+	"vi-end-word": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.repeatMotion(count, le.viWordEnd, false), true, len(le.line) > 0
+	},
+	"vi-end-bigword": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.repeatMotion(count, le.viWordEnd, true), true, len(le.line) > 0
+	},
+	"vi-find-next-char": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.viFindKey('f', count)
+	},
+	"vi-find-prev-char": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.viFindKey('F', count)
+	},
+	"vi-till-next-char": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.viFindKey('t', count)
+	},
+	"vi-till-prev-char": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.viFindKey('T', count)
+	},
+	"vi-repeat-find": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.viFind(le.lastFind.cmd, le.lastFind.r, count)
+	},
+	"vi-reverse-find": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		reverse := map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}
+		return le.viFind(reverse[le.lastFind.cmd], le.lastFind.r, count)
+	},
+	"vi-match": func(le *lineEditor, k key, count int) (int, bool, bool) {
+		return le.viMatch()
+	},
+}
+
+// viFuncs are the editing functions of the Vi command keymap, other than
+// the motions.
+var viFuncs = map[string]editFunc{
+	"vi-movement-mode": func(le *lineEditor, k key) error {
+		le.viCommand = true
+		le.pos = max(le.pos-1, 0)
+		return nil
+	},
+	"vi-insertion-mode": func(le *lineEditor, k key) error {
+		le.viCommand = false
+		return nil
+	},
+	"vi-append-mode": func(le *lineEditor, k key) error {
+		le.pos = min(le.pos+1, len(le.line))
+		le.viCommand = false
+		return nil
+	},
+	"vi-insert-beg": func(le *lineEditor, k key) error {
+		le.pos = le.firstPrint()
+		le.viCommand = false
+		return nil
+	},
+	"vi-append-eol": func(le *lineEditor, k key) error {
+		le.pos = len(le.line)
+		le.viCommand = false
+		return nil
+	},
+	"vi-delete": func(le *lineEditor, k key) error {
+		le.viCut(le.pos, le.pos+le.takeCount())
+		return nil
+	},
+	"vi-rubout": func(le *lineEditor, k key) error {
+		le.viCut(le.pos-le.takeCount(), le.pos)
+		return nil
+	},
+	"vi-change-char": func(le *lineEditor, k key) error {
+		count := le.takeCount()
+		c, err := readKey(le.in)
+		if err != nil || c.alt || c.r >= keyUnknown || !unicode.IsPrint(c.r) || le.pos+count > len(le.line) {
+			return err
+		}
+		for i := le.pos; i < le.pos+count; i++ {
+			le.line[i] = c.r
+		}
+		le.pos += count - 1
+		return nil
+	},
+	"vi-subst": func(le *lineEditor, k key) error {
+		le.viCut(le.pos, le.pos+le.takeCount())
+		le.viCommand = false
+		return nil
+	},
+	"vi-change-line": func(le *lineEditor, k key) error {
+		le.viCut(0, len(le.line))
+		le.viCommand = false
+		return nil
+	},
+	"vi-change-eol": func(le *lineEditor, k key) error {
+		le.viCut(le.pos, len(le.line))
+		le.viCommand = false
+		return nil
+	},
+	"vi-delete-to": func(le *lineEditor, k key) error {
+		return le.viOperator('d', k)
+	},
+	"vi-change-to": func(le *lineEditor, k key) error {
+		return le.viOperator('c', k)
+	},
+	"vi-yank-to": func(le *lineEditor, k key) error {
+		return le.viOperator('y', k)
+	},
+	"vi-put": func(le *lineEditor, k key) error {
+		le.viPut(min(le.pos+1, len(le.line)))
+		return nil
+	},
+	"vi-put-before": func(le *lineEditor, k key) error {
+		le.viPut(le.pos)
+		return nil
+	},
+	"vi-change-case": func(le *lineEditor, k key) error {
+		end := min(le.pos+le.takeCount(), len(le.line))
+		for i := le.pos; i < end; i++ {
+			if r := le.line[i]; unicode.IsUpper(r) {
+				le.line[i] = unicode.ToLower(r)
+			} else {
+				le.line[i] = unicode.ToUpper(r)
+			}
+		}
+		le.pos = end
+		return nil
+	},
+}
+
+// viCount adds k to the count typed before a Vi command, and reports
+// whether it was a digit of the count. A leading '0' is not.
+func (le *lineEditor) viCount(k key) bool {
+	if k.alt || k.r < '0' || k.r > '9' || k.r == '0' && le.count == 0 {
+		return false
+	}
+	le.count = le.count*10 + int(k.r-'0')
+	return true
+}
+
+// takeCount returns the count typed before the command, 1 if none, and
+// clears it.
+func (le *lineEditor) takeCount() int {
+	count := max(le.count, 1)
+	le.count = 0
+	return count
+}
+
+// viMove moves the cursor with a motion.
+func (le *lineEditor) viMove(motion viMotion, k key) {
+	if pos, _, ok := motion(le, k, le.takeCount()); ok {
+		le.pos = pos
+	}
+}
+
+// viOperator applies the operator d (delete), c (change) or y (yank) to
+// the text the next motion moves over. The operator key repeated, as in
+// "dd", applies to the whole line, and 'i' or 'a' followed by a text
+// object to that object.
+func (le *lineEditor) viOperator(op rune, k key) error {
+	count := le.takeCount()
+	next, err := readKey(le.in)
+	if err != nil {
+		return err
+	}
+	// A count may also come between the operator and the motion
+	for le.viCount(next) {
+		if next, err = readKey(le.in); err != nil {
+			return err
+		}
+	}
+	count *= le.takeCount()
+
+	var start, end int
+	switch {
+	case next == k:
+		start, end = 0, len(le.line)
+	case next.r == 'i' || next.r == 'a':
+		obj, err := readKey(le.in)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if start, end, ok = le.textObject(obj.r, next.r == 'a'); !ok {
+			return nil
+		}
+	default:
+		name := keymaps[viCommandKeymap][next]
+		motion, ok := viMotions[name]
+		if !ok {
+			return nil
+		}
+		if op == 'c' && (name == "vi-forward-word" || name == "vi-forward-bigword") &&
+			le.pos < len(le.line) && !unicode.IsSpace(le.line[le.pos]) {
+			// As in vi, "cw" changes up to the end of the word
+			start, end = le.pos, le.viChangeWordEnd(count, name == "vi-forward-bigword")
+			break
+		}
+		target, inclusive, ok := motion(le, next, count)
+		if !ok {
+			return nil
+		}
+		start, end = min(le.pos, target), max(le.pos, target)
+		if inclusive {
+			end++
+		}
+	}
+	end = min(end, len(le.line))
+
+	switch op {
+	case 'y':
+		if start < end {
+			le.saveKill(string(le.line[start:end]))
+		}
+		le.pos = start
+	case 'd':
+		le.viCut(start, end)
+	case 'c':
+		le.viCut(start, end)
+		le.viCommand = false
+	}
+	return nil
+}
+
+// viCut deletes the characters from start to end, clamped to the line,
+// and saves them in the kill ring for 'p'.
+func (le *lineEditor) viCut(start, end int) {
+	start, end = max(start, 0), min(end, len(le.line))
+	if start >= end {
+		return
+	}
+	le.saveKill(string(le.line[start:end]))
+	le.delete(start, end)
+}
+
+// viPut inserts the last kill at i, as many times as the count, leaving
+// the cursor on its last character.
+func (le *lineEditor) viPut(i int) {
+	count := le.takeCount()
+	if len(le.killRing) == 0 {
+		return
+	}
+	le.pos = i
+	le.insertText(strings.Repeat(le.killRing[len(le.killRing)-1], count))
+	le.pos = max(le.pos-1, 0)
+}
+
+// firstPrint returns the position of the first non-blank character.
+func (le *lineEditor) firstPrint() int {
+	i := 0
+	for i < len(le.line) && unicode.IsSpace(le.line[i]) {
+		i++
+	}
+	return i
+}
+
+// viClass returns the class of r for the Vi word motions: 0 for blanks,
+// 1 for word characters and 2 for punctuation. Big words, made of any
+// non-blank characters, have no punctuation class.
+func viClass(r rune, big bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case big || isWordRune(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// repeatMotion applies step count times from the cursor.
+func (le *lineEditor) repeatMotion(count int, step func(int, bool) int, big bool) int {
+	i := le.pos
+	for ; count > 0; count-- {
+		i = step(i, big)
+	}
+	return i
+}
+
+// viWordForward returns the start of the word after i.
+func (le *lineEditor) viWordForward(i int, big bool) int {
+	n := len(le.line)
+	if i < n {
+		c := viClass(le.line[i], big)
+		for i < n && c != 0 && viClass(le.line[i], big) == c {
+			i++
+		}
+	}
+	for i < n && viClass(le.line[i], big) == 0 {
+		i++
+	}
+	return i
+}
+
+// viWordBackward returns the start of the word before i.
+func (le *lineEditor) viWordBackward(i int, big bool) int {
+	for i > 0 && viClass(le.line[i-1], big) == 0 {
+		i--
+	}
+	if i > 0 {
+		c := viClass(le.line[i-1], big)
+		for i > 0 && viClass(le.line[i-1], big) == c {
+			i--
+		}
+	}
+	return i
+}
+
+// viWordEnd returns the last character of the word after i.
+func (le *lineEditor) viWordEnd(i int, big bool) int {
+	n := len(le.line)
+	i++
+	for i < n && viClass(le.line[i], big) == 0 {
+		i++
+	}
+	if i >= n {
+		return max(n-1, 0)
+	}
+	c := viClass(le.line[i], big)
+	for i+1 < n && viClass(le.line[i+1], big) == c {
+		i++
+	}
+	return i
+}
+
+// viChangeWordEnd returns the end of the text "cw" changes: the rest of
+// the word at the cursor and count-1 words after it, without the blanks
+// that follow.
+func (le *lineEditor) viChangeWordEnd(count int, big bool) int {
+	n := len(le.line)
+	i := le.pos
+	for ; count > 0; count-- {
+		for i < n && viClass(le.line[i], big) == 0 {
+			i++
+		}
+		if i < n {
+			c := viClass(le.line[i], big)
+			for i < n && viClass(le.line[i], big) == c {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// viFindKey reads the character to find for f, F, t or T and finds it.
+func (le *lineEditor) viFindKey(cmd rune, count int) (int, bool, bool) {
+	c, err := readKey(le.in)
+	if err != nil || c.alt || c.r >= keyUnknown || !unicode.IsPrint(c.r) {
+		return 0, false, false
+	}
+	le.lastFind.cmd, le.lastFind.r = cmd, c.r
+	return le.viFind(cmd, c.r, count)
+}
+
+// viFind finds the count-th r after the cursor, for f and t, or before
+// it, for F and T. t and T stop next to it.
+func (le *lineEditor) viFind(cmd, r rune, count int) (int, bool, bool) {
+	step := 1
+	if cmd == 'F' || cmd == 'T' {
+		step = -1
+	}
+	i := le.pos
+	if cmd == 't' || cmd == 'T' {
+		// Not stuck next to the character when repeated
+		i += step
+	}
+	for ; count > 0; count-- {
+		i += step
+		for i >= 0 && i < len(le.line) && le.line[i] != r {
+			i += step
+		}
+		if i < 0 || i >= len(le.line) {
+			return 0, false, false
+		}
+	}
+	switch cmd {
+	case 'f':
+		return i, true, true
+	case 't':
+		return i - 1, true, true
+	case 'F':
+		return i, false, true
+	case 'T':
+		return i + 1, false, true
+	}
+	return 0, false, false
+}
+
+// brackets maps each bracket to the one matching it.
+var brackets = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<'}
+
+// viMatch finds the bracket matching the first one at or after the cursor.
+func (le *lineEditor) viMatch() (int, bool, bool) {
+	for i := le.pos; i < len(le.line); i++ {
+		switch le.line[i] {
+		case '(', '[', '{':
+			if j := le.matchBracket(i, 1); j >= 0 {
+				return j, true, true
+			}
+			return 0, false, false
+		case ')', ']', '}':
+			if j := le.matchBracket(i, -1); j >= 0 {
+				return j, true, true
+			}
+			return 0, false, false
+		}
+	}
+	return 0, false, false
+}
+
+// matchBracket returns the bracket matching the one at i, searching in the
+// direction of step, or -1.
+func (le *lineEditor) matchBracket(i, step int) int {
+	open, close := le.line[i], brackets[le.line[i]]
+	depth := 0
+	for ; i >= 0 && i < len(le.line); i += step {
+		switch le.line[i] {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// textObject returns the span of the text object obj at the cursor: a
+// word (w), a big word (W), a quoted string (" ' `) or text in brackets
+// (( ) b [ ] { } B < >). With around set it includes the blanks after
+// the word, or the quotes and brackets themselves.
+func (le *lineEditor) textObject(obj rune, around bool) (int, int, bool) {
+	switch obj {
+	case 'w', 'W':
+		return le.wordObject(obj == 'W', around)
+	case '"', '\'', '`':
+		return le.quoteObject(obj, around)
+	case 'b':
+		return le.bracketObject('(', around)
+	case 'B':
+		return le.bracketObject('{', around)
+	case '(', '[', '{', '<':
+		return le.bracketObject(obj, around)
+	case ')', ']', '}', '>':
+		return le.bracketObject(brackets[obj], around)
+	}
+	return 0, 0, false
+}
+
+// wordObject returns the span of the word or blanks at the cursor.
+func (le *lineEditor) wordObject(big, around bool) (int, int, bool) {
+	n := len(le.line)
+	if n == 0 {
+		return 0, 0, false
+	}
+	i := min(le.pos, n-1)
+	c := viClass(le.line[i], big)
+	start, end := i, i+1
+	for start > 0 && viClass(le.line[start-1], big) == c {
+		start--
+	}
+	for end < n && viClass(le.line[end], big) == c {
+		end++
+	}
+	if around && c != 0 {
+		if end < n && viClass(le.line[end], big) == 0 {
+			for end < n && viClass(le.line[end], big) == 0 {
+				end++
+			}
+		} else {
+			for start > 0 && viClass(le.line[start-1], big) == 0 {
+				start--
+			}
+		}
+	}
+	return start, end, true
+}
+
+// quoteObject returns the span of the string quoted with q around the
+// cursor, or of the next one on the line. Quotes pair up from the start
+// of the line.
+func (le *lineEditor) quoteObject(q rune, around bool) (int, int, bool) {
+	open := -1
+	for i, r := range le.line {
+		if r != q {
+			continue
+		}
+		if open < 0 {
+			open = i
+			continue
+		}
+		if i >= le.pos {
+			if around {
+				return open, i + 1, true
+			}
+			return open + 1, i, true
+		}
+		open = -1
+	}
+	return 0, 0, false
+}
+
+// bracketObject returns the span of the text in the brackets opened with
+// open around the cursor.
+func (le *lineEditor) bracketObject(open rune, around bool) (int, int, bool) {
+	close := brackets[open]
+	start := -1
+	depth := 0
+	for i := min(le.pos, len(le.line)-1); i >= 0; i-- {
+		switch {
+		case le.line[i] == close && i != le.pos:
+			depth++
+		case le.line[i] == open && depth == 0:
+			start = i
+		case le.line[i] == open:
+			depth--
+		}
+		if start >= 0 {
+			break
+		}
+	}
+	if start < 0 {
+		return 0, 0, false
+	}
+	end := le.matchBracket(start, 1)
+	if end < 0 {
+		return 0, 0, false
+	}
+	if around {
+		return start, end + 1, true
+	}
+	return start + 1, end, true
+}
@@ -0,0 +1,44 @@
+package signals
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Name returns the name of sig without the SIG prefix, such as "INT", or
+// its number if the shell does not know it.
+func Name(sig syscall.Signal) string {
+	if name, ok := names[sig]; ok {
+		return name
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// Parse returns the signal named s, given as a name in any case with or
+// without the SIG prefix, or as a number.
+func Parse(s string) (syscall.Signal, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		sig := syscall.Signal(n)
+		_, ok := names[sig]
+		return sig, ok
+	}
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	for sig, n := range names {
+		if n == name {
+			return sig, true
+		}
+	}
+	return 0, false
+}
+
+// List returns the signals known to the shell, ordered by number.
+func List() []syscall.Signal {
+	sigs := make([]syscall.Signal, 0, len(names))
+	for sig := range names {
+		sigs = append(sigs, sig)
+	}
+	slices.Sort(sigs)
+	return sigs
+}
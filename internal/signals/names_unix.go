@@ -0,0 +1,54 @@
+//go:build !windows
+
+package signals
+
+import "syscall"
+
+// names maps the signals known to the shell to their names without the
+// SIG prefix.
+var names = map[syscall.Signal]string{
+	syscall.SIGHUP:    "HUP",
+	syscall.SIGINT:    "INT",
+	syscall.SIGQUIT:   "QUIT",
+	syscall.SIGILL:    "ILL",
+	syscall.SIGTRAP:   "TRAP",
+	syscall.SIGABRT:   "ABRT",
+	syscall.SIGBUS:    "BUS",
+	syscall.SIGFPE:    "FPE",
+	syscall.SIGKILL:   "KILL",
+	syscall.SIGUSR1:   "USR1",
+	syscall.SIGSEGV:   "SEGV",
+	syscall.SIGUSR2:   "USR2",
+	syscall.SIGPIPE:   "PIPE",
+	syscall.SIGALRM:   "ALRM",
+	syscall.SIGTERM:   "TERM",
+	syscall.SIGCHLD:   "CHLD",
+	syscall.SIGCONT:   "CONT",
+	syscall.SIGSTOP:   "STOP",
+	syscall.SIGTSTP:   "TSTP",
+	syscall.SIGTTIN:   "TTIN",
+	syscall.SIGTTOU:   "TTOU",
+	syscall.SIGURG:    "URG",
+	syscall.SIGXCPU:   "XCPU",
+	syscall.SIGXFSZ:   "XFSZ",
+	syscall.SIGVTALRM: "VTALRM",
+	syscall.SIGPROF:   "PROF",
+	syscall.SIGWINCH:  "WINCH",
+	syscall.SIGIO:     "IO",
+	syscall.SIGSYS:    "SYS",
+}
+
+// caught are the signals the shell always catches, for itself or for job
+// control, so a trap that is reset goes back to being caught rather than
+// to the default action.
+var caught = []syscall.Signal{
+	syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP,
+	syscall.SIGCHLD, syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU,
+}
+
+// uncatchable are the signals whose action cannot be changed.
+var uncatchable = []syscall.Signal{syscall.SIGKILL, syscall.SIGSTOP}
+
+// unignorable are the signals the shell keeps catching when their trap is
+// empty, since job control relies on them.
+var unignorable = []syscall.Signal{syscall.SIGCHLD}
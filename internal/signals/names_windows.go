@@ -0,0 +1,36 @@
+//go:build windows
+
+package signals
+
+import "syscall"
+
+// names maps the signals known to the shell to their names without the
+// SIG prefix.
+var names = map[syscall.Signal]string{
+	syscall.SIGHUP:  "HUP",
+	syscall.SIGINT:  "INT",
+	syscall.SIGQUIT: "QUIT",
+	syscall.SIGILL:  "ILL",
+	syscall.SIGTRAP: "TRAP",
+	syscall.SIGABRT: "ABRT",
+	syscall.SIGBUS:  "BUS",
+	syscall.SIGFPE:  "FPE",
+	syscall.SIGKILL: "KILL",
+	syscall.SIGSEGV: "SEGV",
+	syscall.SIGPIPE: "PIPE",
+	syscall.SIGALRM: "ALRM",
+	syscall.SIGTERM: "TERM",
+}
+
+// caught are the signals the shell always catches, so a trap that is reset
+// goes back to being caught rather than to the default action.
+var caught = []syscall.Signal{
+	syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP,
+}
+
+// uncatchable are the signals whose action cannot be changed.
+var uncatchable = []syscall.Signal{syscall.SIGKILL}
+
+// unignorable are the signals the shell keeps catching when their trap is
+// empty.
+var unignorable []syscall.Signal
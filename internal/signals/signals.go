@@ -0,0 +1,148 @@
+// Package signals handles the signals the shell receives. SIGINT and
+// SIGQUIT do not kill the shell: they interrupt the command running in the
+// foreground, whose processes are sent the signal too when it did not come
+// from the terminal. SIGTERM and SIGHUP end the shell. A signal with a trap
+// set by the 'trap' builtin does neither: it is recorded for the evaluator
+// to run the trap between commands.
+package signals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"dush/internal/jobs"
+)
+
+// Interrupt is the cause of a foreground context cancelled by a signal.
+type Interrupt struct {
+	Signal  syscall.Signal
+	Forward bool // The processes of the command did not receive the signal themselves
+}
+
+func (i *Interrupt) Error() string {
+	return fmt.Sprintf("interrupted by %v", i.Signal)
+}
+
+var (
+	installOnce sync.Once
+	received    = make(chan os.Signal, 16)
+
+	mu        sync.Mutex
+	interrupt context.CancelCauseFunc // Cancels the foreground command, if any
+	terminate context.CancelCauseFunc // Cancels the shell, if any
+)
+
+// Install makes the shell catch SIGINT, SIGQUIT, SIGTERM and SIGHUP.
+// Catching rather than ignoring them leaves them at their default in the
+// processes it starts.
+func Install() {
+	installOnce.Do(func() {
+		signal.Notify(received, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGHUP)
+		go func() {
+			for sig := range received {
+				handle(sig.(syscall.Signal))
+			}
+		}()
+	})
+}
+
+// handle acts on a signal received by the shell.
+func handle(sig syscall.Signal) {
+	if queueTrap(sig) {
+		return
+	}
+	switch sig {
+	case syscall.SIGINT, syscall.SIGQUIT:
+		cancelForeground(&Interrupt{Signal: sig, Forward: !jobs.ShellOwnsTerminal()})
+	case syscall.SIGTERM, syscall.SIGHUP:
+		mu.Lock()
+		defer mu.Unlock()
+		if terminate != nil {
+			terminate(&Interrupt{Signal: sig, Forward: true})
+		}
+	}
+}
+
+// ShellContext returns a copy of ctx for the lifetime of the shell, which
+// SIGTERM and SIGHUP cancel unless they are trapped, and a function to
+// call when the shell is done.
+func ShellContext(ctx context.Context) (context.Context, func()) {
+	Install()
+	ctx, cancel := context.WithCancelCause(ctx)
+	mu.Lock()
+	terminate = cancel
+	mu.Unlock()
+	return ctx, func() {
+		mu.Lock()
+		terminate = nil
+		mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// Foreground returns a copy of ctx for running a command in the
+// foreground, which SIGINT and SIGQUIT cancel, and a function to call once
+// the command is done.
+func Foreground(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	release := SetForeground(cancel)
+	return ctx, func() {
+		release()
+		cancel(nil)
+	}
+}
+
+// SetForeground makes SIGINT and SIGQUIT call cancel, for a command that
+// takes over the foreground, until the returned function is called. The
+// commands it is part of are cancelled too, so that the rest of a list
+// does not run after an interrupt.
+func SetForeground(cancel context.CancelCauseFunc) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	outer := interrupt
+	interrupt = func(cause error) {
+		cancel(cause)
+		if outer != nil {
+			outer(cause)
+		}
+	}
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		interrupt = outer
+	}
+}
+
+// Interrupted cancels the foreground command as if the shell had received
+// sig, as when one of its processes was killed by it. If sig is trapped,
+// its trap runs instead.
+func Interrupted(sig syscall.Signal) {
+	if queueTrap(sig) {
+		return
+	}
+	cancelForeground(&Interrupt{Signal: sig})
+}
+
+func cancelForeground(cause *Interrupt) {
+	mu.Lock()
+	defer mu.Unlock()
+	if interrupt != nil {
+		interrupt(cause)
+	}
+}
+
+// ExitStatus returns the exit status of a command whose context was
+// cancelled: 128 plus the number of the interrupting signal, or 130 if the
+// context was cancelled otherwise.
+func ExitStatus(ctx context.Context) int {
+	var intr *Interrupt
+	if errors.As(context.Cause(ctx), &intr) {
+		return 128 + int(intr.Signal)
+	}
+	return 128 + int(syscall.SIGINT)
+}
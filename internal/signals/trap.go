@@ -0,0 +1,67 @@
+package signals
+
+import (
+	"fmt"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"dush/internal/app"
+)
+
+// pending are the trapped signals received since the evaluator last ran
+// their traps, in order of arrival. Guarded by mu.
+var pending []syscall.Signal
+
+// Trappable reports an error if the trap for sig cannot be changed.
+func Trappable(sig syscall.Signal) error {
+	if slices.Contains(uncatchable, sig) {
+		return fmt.Errorf("SIG%s: cannot be trapped", Name(sig))
+	}
+	return nil
+}
+
+// Update makes the shell handle sig according to its trap: a signal whose
+// trap is empty is ignored, by the processes the shell starts as well, and
+// a signal with a command is caught. Without a trap sig goes back to its
+// default, unless the shell needs to catch it itself.
+func Update(sig syscall.Signal) {
+	Install()
+	action, trapped := app.GetApp().Trap(Name(sig))
+	switch {
+	case trapped && action == "" && !slices.Contains(unignorable, sig):
+		signal.Ignore(sig)
+	case trapped || slices.Contains(caught, sig):
+		signal.Notify(received, sig)
+	default:
+		signal.Reset(sig)
+	}
+}
+
+// queueTrap records sig as pending if it has a trap and reports whether it
+// was trapped. A signal received again before its trap runs is recorded
+// once.
+func queueTrap(sig syscall.Signal) bool {
+	action, trapped := app.GetApp().Trap(Name(sig))
+	if !trapped {
+		return false
+	}
+	if action != "" {
+		mu.Lock()
+		defer mu.Unlock()
+		if !slices.Contains(pending, sig) {
+			pending = append(pending, sig)
+		}
+	}
+	return true
+}
+
+// TakePending returns the trapped signals received since the last call, in
+// order of arrival.
+func TakePending() []syscall.Signal {
+	mu.Lock()
+	defer mu.Unlock()
+	sigs := pending
+	pending = nil
+	return sigs
+}
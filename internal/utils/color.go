@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"io"
+	"strings"
 )
 
 // ANSI escape codes for text colors
@@ -64,3 +65,45 @@ func FprintColor(w io.Writer, text, color string) (int, error) {
 func FprintlnColor(w io.Writer, text, color string) (int, error) {
 	return fmt.Fprintln(w, Colorize(text, color))
 }
+
+// colorNames are the names of the colors and styles above in configuration.
+var colorNames = map[string]string{
+	"black":          ColorBlack,
+	"red":            ColorRed,
+	"green":          ColorGreen,
+	"yellow":         ColorYellow,
+	"blue":           ColorBlue,
+	"magenta":        ColorMagenta,
+	"cyan":           ColorCyan,
+	"white":          ColorWhite,
+	"bright-black":   ColorBrightBlack,
+	"bright-red":     ColorBrightRed,
+	"bright-green":   ColorBrightGreen,
+	"bright-yellow":  ColorBrightYellow,
+	"bright-blue":    ColorBrightBlue,
+	"bright-magenta": ColorBrightMagenta,
+	"bright-cyan":    ColorBrightCyan,
+	"bright-white":   ColorBrightWhite,
+	"bold":           StyleBold,
+	"faint":          StyleFaint,
+	"italic":         StyleItalic,
+	"underline":      StyleUnderline,
+	"reverse":        StyleReverse,
+}
+
+// ParseColor returns the escape codes of a space-separated list of color
+// and style names, such as "bold green". "none" adds no codes.
+func ParseColor(spec string) (string, error) {
+	var codes strings.Builder
+	for _, name := range strings.Fields(spec) {
+		if strings.EqualFold(name, "none") {
+			continue
+		}
+		code, ok := colorNames[strings.ToLower(name)]
+		if !ok {
+			return "", fmt.Errorf("unknown color '%s'", name)
+		}
+		codes.WriteString(code)
+	}
+	return codes.String(), nil
+}
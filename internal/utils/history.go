@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync" // Import sync package for mutex
 )
@@ -12,8 +14,24 @@ import (
 const historyFileName = ".dush_history"
 const maxHistorySize = 1000 // Limit the history to prevent excessively large files
 
+// historyInfoFileName is the file holding what is known of the commands
+// of the history besides their text, kept apart so that versions reading
+// the history file line by line are not confused by it. Each line holds
+// the escaped command, the directory it last ran in and the number of
+// times it ran, separated by tabs.
+const historyInfoFileName = ".dush_history_info"
+
+// commandInfo is what the history info file holds of a command.
+type commandInfo struct {
+	dir   string // Directory it last ran in, "" if not known
+	count int    // Times it ran
+}
+
 var commandHistory []string
-var historyMutex sync.Mutex // Mutex to protect commandHistory and file operations
+var sessionStart int                            // Index in commandHistory of the first command of this session
+var commandInfos = make(map[string]commandInfo) // Info of each command, as loaded and updated since
+var sessionCounts = make(map[string]int)        // Times each command ran since the history was saved
+var historyMutex sync.Mutex                     // Mutex to protect commandHistory and file operations
 
 // getHistoryFilePath returns the full path to the history file.
 func getHistoryFilePath() (string, error) {
@@ -32,7 +50,54 @@ func getHistoryFilePath() (string, error) {
 	return filepath.Join(dushDir, historyFileName), nil
 }
 
+// historyInfoPath returns the path of the file holding the directories
+// of the commands of the history file at filePath.
+func historyInfoPath(filePath string) string {
+	return filepath.Join(filepath.Dir(filePath), historyInfoFileName)
+}
+
+// historyHeader is the first line of a history file whose lines are
+// escaped with escapeHistory. Files without it hold one command per line
+// as is, as older versions wrote them; to those it is a comment.
+const historyHeader = "#dush history: escaped"
+
+// historyEscaper escapes a command for a line of the history file.
+var historyEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// escapeHistory escapes command so that it takes a single line without
+// tabs: backslashes are doubled, and line breaks and tabs written as \n,
+// \r and \t.
+func escapeHistory(command string) string {
+	return historyEscaper.Replace(command)
+}
+
+// unescapeHistory reverses escapeHistory.
+func unescapeHistory(line string) string {
+	if !strings.Contains(line, `\`) {
+		return line
+	}
+	var sb strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) {
+			i++
+			switch c = line[i]; c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			}
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
 // readHistoryFile reads history from the file, returns a new slice.
+// In files starting with historyHeader, commands spanning several lines
+// are escaped to a single one.
 func readHistoryFile(filePath string) ([]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -45,8 +110,17 @@ func readHistoryFile(filePath string) ([]string, error) {
 
 	var historyFromFile []string
 	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		historyFromFile = append(historyFromFile, scanner.Text())
+	escaped := false
+	for first := true; scanner.Scan(); first = false {
+		line := scanner.Text()
+		if first && line == historyHeader {
+			escaped = true
+			continue
+		}
+		if escaped {
+			line = unescapeHistory(line)
+		}
+		historyFromFile = append(historyFromFile, line)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -55,6 +129,55 @@ func readHistoryFile(filePath string) ([]string, error) {
 	return historyFromFile, nil
 }
 
+// readHistoryInfo reads the info of the commands from the file next to
+// the history file.
+func readHistoryInfo(filePath string) (map[string]commandInfo, error) {
+	infos := make(map[string]commandInfo)
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return infos, nil
+		}
+		return nil, fmt.Errorf("error opening history info file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		info := commandInfo{dir: unescapeHistory(fields[1])}
+		if len(fields) >= 3 {
+			info.count, _ = strconv.Atoi(fields[2])
+		}
+		infos[unescapeHistory(fields[0])] = info
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history info file: %w", err)
+	}
+	return infos, nil
+}
+
+// writeHistoryInfo writes the info of the commands to the file next to
+// the history file.
+func writeHistoryInfo(filePath string, commands []string, infos map[string]commandInfo) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening history info file for writing: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, cmd := range commands {
+		if info, ok := infos[cmd]; ok {
+			fmt.Fprintf(writer, "%s\t%s\t%d\n", escapeHistory(cmd), escapeHistory(info.dir), info.count)
+		}
+	}
+	return writer.Flush()
+}
+
 // LoadHistory loads command history from the history file into memory.
 func LoadHistory() {
 	historyMutex.Lock()
@@ -63,26 +186,38 @@ func LoadHistory() {
 	filePath, err := getHistoryFilePath()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting history file path: %v\n", err)
-		commandHistory = make([]string, 0)
+		commandHistory, sessionStart = make([]string, 0), 0
 		return
 	}
 
 	loadedHistory, err := readHistoryFile(filePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading history from file: %v\n", err)
-		commandHistory = make([]string, 0)
+		commandHistory, sessionStart = make([]string, 0), 0
 		return
 	}
 
 	commandHistory = loadedHistory
+	if loadedInfos, err := readHistoryInfo(historyInfoPath(filePath)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading history info: %v\n", err)
+	} else {
+		commandInfos = loadedInfos
+	}
 	// Trim history if it exceeds maxHistorySize
 	if len(commandHistory) > maxHistorySize {
 		commandHistory = commandHistory[len(commandHistory)-maxHistorySize:]
 	}
+	sessionStart = len(commandHistory)
 }
 
 // AddCommand adds a command to the in-memory history.
 func AddCommand(command string) {
+	AddCommandIn(command, "")
+}
+
+// AddCommandIn adds a command run in the directory dir to the in-memory
+// history.
+func AddCommandIn(command, dir string) {
 	historyMutex.Lock()
 	defer historyMutex.Unlock()
 
@@ -92,8 +227,16 @@ func AddCommand(command string) {
 	}
 
 	commandHistory = append(commandHistory, trimmedCommand)
+	info := commandInfos[trimmedCommand]
+	if dir != "" {
+		info.dir = dir
+	}
+	info.count++
+	commandInfos[trimmedCommand] = info
+	sessionCounts[trimmedCommand]++
 	if len(commandHistory) > maxHistorySize {
 		commandHistory = commandHistory[1:] // Remove the oldest command
+		sessionStart = max(sessionStart-1, 0)
 	}
 }
 
@@ -116,28 +259,35 @@ func SaveHistory() {
 		// If we can't read, we'll just write our current in-memory history (commandHistory)
 		fileHistory = []string{} // Treat as empty to avoid nil issues
 	}
-
-	// 2. Combine and deduplicate
-	var mergedHistory []string
-	seen := make(map[string]bool)
-
-	// Add fileHistory to mergedHistory, keeping unique and in order
-	for _, cmd := range fileHistory {
-		trimmedCmd := strings.TrimSpace(cmd)
-		if trimmedCmd != "" && !seen[trimmedCmd] {
-			mergedHistory = append(mergedHistory, trimmedCmd)
-			seen[trimmedCmd] = true
+	fileInfos, err := readHistoryInfo(historyInfoPath(filePath))
+	if err != nil {
+		fileInfos = map[string]commandInfo{}
+	}
+	// Runs of this session add to the counts in the file, and their
+	// directories win over those there
+	for cmd, n := range sessionCounts {
+		info := fileInfos[cmd]
+		if dir := commandInfos[cmd].dir; dir != "" {
+			info.dir = dir
 		}
+		info.count += n
+		fileInfos[cmd] = info
 	}
 
-	// Add current session's commandHistory to mergedHistory, keeping unique and in order
-	for _, cmd := range commandHistory {
-		trimmedCmd := strings.TrimSpace(cmd)
+	// 2. Combine and deduplicate. A command run again keeps its last
+	// place, so that the order stays that of the last runs; commands
+	// loaded from the file keep the place they have there.
+	combined := append(fileHistory, commandHistory[sessionStart:]...)
+	var mergedHistory []string
+	seen := make(map[string]bool)
+	for i := len(combined) - 1; i >= 0; i-- {
+		trimmedCmd := strings.TrimSpace(combined[i])
 		if trimmedCmd != "" && !seen[trimmedCmd] {
 			mergedHistory = append(mergedHistory, trimmedCmd)
 			seen[trimmedCmd] = true
 		}
 	}
+	slices.Reverse(mergedHistory)
 
 	// 3. Trim to maxHistorySize
 	if len(mergedHistory) > maxHistorySize {
@@ -152,15 +302,24 @@ func SaveHistory() {
 	}
 	defer file.Close()
 
+	// Commands are escaped to a line each
 	writer := bufio.NewWriter(file)
+	writer.WriteString(historyHeader + "\n")
 	for _, cmd := range mergedHistory {
-		_, err := writer.WriteString(cmd + "\n")
+		_, err := writer.WriteString(escapeHistory(cmd) + "\n")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing command to history file: %v\n", err)
 			return
 		}
 	}
 	writer.Flush()
+
+	if err := writeHistoryInfo(historyInfoPath(filePath), mergedHistory, fileInfos); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving history info: %v\n", err)
+		return
+	}
+	commandInfos, sessionCounts = fileInfos, make(map[string]int)
+	sessionStart = len(commandHistory)
 }
 
 // GetHistory returns a copy of the current in-memory command history.
@@ -172,3 +331,18 @@ func GetHistory() []string {
 	copy(historyCopy, commandHistory)
 	return historyCopy
 }
+
+// CommandDir returns the directory command last ran in, "" if not known.
+func CommandDir(command string) string {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	return commandInfos[command].dir
+}
+
+// CommandCount returns the number of times command ran, as far as the
+// history knows, 0 if not known.
+func CommandCount(command string) int {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	return commandInfos[command].count
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryEscaping(t *testing.T) {
+	for _, cmd := range []string{`echo foo\\`, `cd C:\`, "if true\nthen echo \\n\nfi", `a\nb`, "x\r", "a\tb"} {
+		if got := unescapeHistory(escapeHistory(cmd)); got != cmd {
+			t.Errorf("unescapeHistory(escapeHistory(%q)) = %q", cmd, got)
+		}
+	}
+}
+
+func TestReadHistoryFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		// Files of older versions are read as is
+		{"echo a\\\ncd C:\\\n#dir:/tmp\nls\n", []string{`echo a\`, `cd C:\`, "#dir:/tmp", "ls"}},
+		{historyHeader + "\necho foo\\\\\\\\\nif x\\nthen y\\nfi\nls\n", []string{`echo foo\\`, "if x\nthen y\nfi", "ls"}},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "history")
+		if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readHistoryFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%d: got %q, want %q", i, got, tt.want)
+			continue
+		}
+		for j := range got {
+			if got[j] != tt.want[j] {
+				t.Errorf("%d: got %q, want %q", i, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestHistoryInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyInfoFileName)
+	infos := map[string]commandInfo{
+		"ls":             {dir: "/tmp", count: 3},
+		"echo a\tb\nc":   {dir: `C:\dir`, count: 1},
+		"echo unknown":   {count: 2},
+		"not in history": {dir: "/", count: 5},
+	}
+	commands := []string{"ls", "echo a\tb\nc", "echo unknown"}
+	if err := writeHistoryInfo(path, commands, infos); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readHistoryInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(commands) {
+		t.Errorf("got %d commands, want %d", len(got), len(commands))
+	}
+	for _, cmd := range commands {
+		if got[cmd] != infos[cmd] {
+			t.Errorf("%q: got %+v, want %+v", cmd, got[cmd], infos[cmd])
+		}
+	}
+}
+
+func TestSaveHistoryOrder(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path, err := getHistoryFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(historyHeader+"\na\nb\nc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	LoadHistory()
+	AddCommandIn("a", home)
+	AddCommandIn("d", home)
+	AddCommandIn("b", home)
+	// Another session saves meanwhile
+	if err := os.WriteFile(path, []byte(historyHeader+"\na\nb\nc\ne\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	SaveHistory()
+	LoadHistory()
+
+	want := []string{"c", "e", "a", "d", "b"}
+	got := GetHistory()
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if n := CommandCount("a"); n != 1 {
+		t.Errorf("CommandCount(a) = %d, want 1", n)
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// LookPath searches for an executable named name in the directories of
+// pathList (a PATH-style list). Names containing a path separator are not
+// searched for and are resolved relative to dir instead. The shell keeps its
+// own PATH and working directory, so exec.LookPath cannot be used directly.
+// When nothing is found the error is an *exec.Error wrapping exec.ErrNotFound.
+func LookPath(name, pathList, dir string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if found, ok := findExecutable(path); ok {
+			return found, nil
+		}
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+
+	for _, d := range filepath.SplitList(pathList) {
+		if d == "" {
+			d = "." // An empty PATH entry means the current directory
+		}
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(dir, d)
+		}
+		if found, ok := findExecutable(filepath.Join(d, name)); ok {
+			return found, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// findExecutable reports whether path names an executable file, trying the
+// PATHEXT extensions on Windows.
+func findExecutable(path string) (string, bool) {
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			return "", false
+		}
+		return path, true
+	}
+
+	candidates := []string{path}
+	if filepath.Ext(path) == "" {
+		exts := os.Getenv("PATHEXT")
+		if exts == "" {
+			exts = ".com;.exe;.bat;.cmd"
+		}
+		for _, ext := range strings.Split(exts, ";") {
+			if ext != "" {
+				candidates = append(candidates, path+strings.ToLower(ext))
+			}
+		}
+	}
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && !info.IsDir() {
+			return c, true
+		}
+	}
+	return "", false
+}